@@ -0,0 +1,119 @@
+/*
+Copyright 2023 Verlag der Tagesspiegel GmbH
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AccessRequestPhase describes the lifecycle of an AccessRequest
+// +kubebuilder:validation:Enum=Pending;Active;Denied;Expired
+type AccessRequestPhase string
+
+const (
+	// AccessRequestPending means the request waits for an approval
+	AccessRequestPending AccessRequestPhase = "Pending"
+	// AccessRequestActive means the request was approved and the RoleBinding exists
+	AccessRequestActive AccessRequestPhase = "Active"
+	// AccessRequestDenied means the request was rejected by an approver
+	AccessRequestDenied AccessRequestPhase = "Denied"
+	// AccessRequestExpired means the granted access ran out and the RoleBinding was removed
+	AccessRequestExpired AccessRequestPhase = "Expired"
+)
+
+// AccessRequestSpec defines the desired state of AccessRequest
+type AccessRequestSpec struct {
+	// Subject is the user, group or ServiceAccount requesting access
+	Subject rbacv1.Subject `json:"subject"`
+
+	// RoleRef references the Role or ClusterRole to bind in the namespace of the request
+	RoleRef rbacv1.RoleRef `json:"roleRef"`
+
+	// Duration is the amount of time the access is granted for once approved
+	Duration metav1.Duration `json:"duration"`
+
+	// Reason explains why the access is needed
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Approval is recorded by a member of the approver groups.
+	// It can't be set on creation and can't be changed once set.
+	// +optional
+	Approval *AccessRequestApproval `json:"approval,omitempty"`
+}
+
+// AccessRequestApproval records the decision of an approver
+type AccessRequestApproval struct {
+	// Approved grants the requested access if true and denies it otherwise
+	Approved bool `json:"approved"`
+
+	// Approver is the username of the approver. It has to match the user recording the approval.
+	Approver string `json:"approver"`
+
+	// Comment is an optional note of the approver
+	// +optional
+	Comment string `json:"comment,omitempty"`
+}
+
+// AccessRequestStatus defines the observed state of AccessRequest
+type AccessRequestStatus struct {
+	// Phase is the current lifecycle phase of the request
+	// +optional
+	Phase AccessRequestPhase `json:"phase,omitempty"`
+
+	// ApprovedAt is the point in time the controller observed the approval
+	// +optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+
+	// ExpiresAt is the point in time the access is revoked
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// RoleBindingName is the name of the RoleBinding granting the access
+	// +optional
+	RoleBindingName string `json:"roleBindingName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Subject",type=string,JSONPath=`.spec.subject.name`
+//+kubebuilder:printcolumn:name="Role",type=string,JSONPath=`.spec.roleRef.name`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+
+// AccessRequest is a request for temporary access to the namespace it is created in
+type AccessRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   AccessRequestSpec   `json:"spec,omitempty"`
+	Status AccessRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// AccessRequestList contains a list of AccessRequest
+type AccessRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AccessRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&AccessRequest{}, &AccessRequestList{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023 Verlag der Tagesspiegel GmbH
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BreakGlassPhase describes the lifecycle of a BreakGlass
+// +kubebuilder:validation:Enum=Active;Expired;Rejected
+type BreakGlassPhase string
+
+const (
+	// BreakGlassActive means the emergency access is granted
+	BreakGlassActive BreakGlassPhase = "Active"
+	// BreakGlassExpired means the emergency access ran out and the RoleBinding was removed
+	BreakGlassExpired BreakGlassPhase = "Expired"
+	// BreakGlassRejected means the access can't be granted, e.g. because the namespace is protected
+	BreakGlassRejected BreakGlassPhase = "Rejected"
+)
+
+// BreakGlassSpec defines the desired state of BreakGlass
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type BreakGlassSpec struct {
+	// Namespace is the managed namespace the emergency access is granted in
+	// +kubebuilder:validation:MinLength=1
+	Namespace string `json:"namespace"`
+
+	// Subject is the user, group or ServiceAccount receiving the emergency access
+	Subject rbacv1.Subject `json:"subject"`
+
+	// Duration is the amount of time the access is granted for. It has to be positive
+	// and must not exceed the maximum duration configured in the controller.
+	// +kubebuilder:validation:XValidation:rule="duration(self) > duration('0s')",message="duration has to be positive"
+	Duration metav1.Duration `json:"duration"`
+
+	// Reason explains the incident the access is needed for
+	// +kubebuilder:validation:MinLength=1
+	Reason string `json:"reason"`
+}
+
+// BreakGlassStatus defines the observed state of BreakGlass
+type BreakGlassStatus struct {
+	// Phase is the current lifecycle phase of the emergency access
+	// +optional
+	Phase BreakGlassPhase `json:"phase,omitempty"`
+
+	// Message explains why the access was rejected
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// GrantedAt is the point in time the access was granted
+	// +optional
+	GrantedAt *metav1.Time `json:"grantedAt,omitempty"`
+
+	// ExpiresAt is the point in time the access is revoked
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// RoleBindingName is the name of the RoleBinding granting the access
+	// +optional
+	RoleBindingName string `json:"roleBindingName,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Namespace",type=string,JSONPath=`.spec.namespace`
+//+kubebuilder:printcolumn:name="Subject",type=string,JSONPath=`.spec.subject.name`
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Expires",type=date,JSONPath=`.status.expiresAt`
+
+// BreakGlass grants emergency access to a managed namespace for a short amount of time
+type BreakGlass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BreakGlassSpec   `json:"spec,omitempty"`
+	Status BreakGlassStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BreakGlassList contains a list of BreakGlass
+type BreakGlassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BreakGlass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BreakGlass{}, &BreakGlassList{})
+}
@@ -0,0 +1,247 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// kubectl-nsperm is a kubectl plugin showing the effective permissions granted by the namespace permission manager
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/cli"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/inspect"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+	switch args[0] {
+	case "who-can":
+		return runWhoCan(args[1:], stdout, stderr)
+	case "where":
+		return runWhere(args[1:], stdout, stderr)
+	case "-h", "--help", "help":
+		usage(stderr)
+		return exitUsage
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: kubectl nsperm <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	fmt.Fprintln(w, "  who-can <namespace>  List every subject with access to a managed namespace and what it can do")
+	fmt.Fprintln(w, "  where <subject>      List every managed namespace a subject has access to")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Subjects are given as User/<name>, Group/<name>, ServiceAccount/<namespace>/<name>")
+	fmt.Fprintln(w, "or in the format of the rolebinding-subjects annotation, e.g. 'kind=User;name=jane'.")
+}
+
+// parse parses the flags of a command, which may be given before and after its single argument
+func parse(fs *flag.FlagSet, args []string) (string, bool) {
+	if err := fs.Parse(args); err != nil {
+		return "", false
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return "", false
+	}
+	arg := fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		return "", false
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return "", false
+	}
+	return arg, true
+}
+
+func runWhoCan(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("who-can", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	kube := cli.AddKubeFlags(fs)
+	output := fs.String("o", formatTable, "Output format, one of table or json.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: kubectl nsperm who-can <namespace> [flags]")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	namespace, ok := parse(fs, args)
+	if !ok {
+		return exitUsage
+	}
+	c, err := kube.Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to create client: %s\n", err)
+		return exitError
+	}
+	grants, err := inspect.WhoCan(context.Background(), c, namespace)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	switch *output {
+	case formatJSON:
+		return writeJSON(stdout, stderr, grants)
+	case formatTable:
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *output)
+		return exitUsage
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SUBJECT\tBINDING\tROLE\tVERBS\tAPI GROUPS\tRESOURCES\tEXPIRES")
+	for _, g := range grants {
+		subject, role := formatSubject(g.Subject), g.RoleRef.Kind+"/"+g.RoleRef.Name
+		if g.Error != "" {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t<%s>\t\t\t%s\n", subject, g.Binding, role, g.Error, formatExpiry(g.Expires))
+		}
+		for _, rule := range g.Rules {
+			resources := rule.Resources
+			if len(rule.NonResourceURLs) > 0 {
+				resources = rule.NonResourceURLs
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", subject, g.Binding, role,
+				strings.Join(rule.Verbs, ","), formatAPIGroups(rule.APIGroups), formatResources(resources, rule.ResourceNames), formatExpiry(g.Expires))
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func runWhere(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("where", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	kube := cli.AddKubeFlags(fs)
+	output := fs.String("o", formatTable, "Output format, one of table or json.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: kubectl nsperm where <subject> [flags]")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	arg, ok := parse(fs, args)
+	if !ok {
+		return exitUsage
+	}
+	subject, err := inspect.ParseSubject(arg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	c, err := kube.Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to create client: %s\n", err)
+		return exitError
+	}
+	access, err := inspect.Where(context.Background(), c, subject)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	switch *output {
+	case formatJSON:
+		return writeJSON(stdout, stderr, access)
+	case formatTable:
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *output)
+		return exitUsage
+	}
+	tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tBINDING\tROLE\tEXPIRES")
+	for _, a := range access {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", a.Namespace, a.Binding, a.RoleRef.Kind+"/"+a.RoleRef.Name, formatExpiry(a.Expires))
+	}
+	if err := tw.Flush(); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func writeJSON(stdout, stderr io.Writer, v any) int {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+	return exitOK
+}
+
+func formatSubject(s rbacv1.Subject) string {
+	if s.Namespace != "" {
+		return s.Kind + "/" + s.Namespace + "/" + s.Name
+	}
+	return s.Kind + "/" + s.Name
+}
+
+func formatAPIGroups(groups []string) string {
+	out := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if g == "" {
+			g = "core"
+		}
+		out = append(out, g)
+	}
+	return strings.Join(out, ",")
+}
+
+func formatResources(resources, names []string) string {
+	str := strings.Join(resources, ",")
+	if len(names) > 0 {
+		str += " [" + strings.Join(names, ",") + "]"
+	}
+	return str
+}
+
+func formatExpiry(expires *time.Time) string {
+	if expires == nil {
+		return "never"
+	}
+	return expires.Format(time.RFC3339)
+}
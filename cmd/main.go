@@ -17,22 +17,42 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/selection"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
 	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/debug"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/health"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/inventory"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/policy"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/shard"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/tracing"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/webhook"
+	strc "github.com/tagesspiegel/kubernetes-namespace-permission-manager/utils/strings"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,6 +63,7 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(nsv1alpha1.AddToScheme(scheme))
 
 	//+kubebuilder:scaffold:scheme
 }
@@ -51,11 +72,121 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var policyFile string
+	var protectedNamespaces string
+	var deniedSubjects string
+	var trustedFieldManagers string
+	var enableWebhooks bool
+	var protectManagedRBAC bool
+	var controllerServiceAccount string
+	var breakGlassGroups string
+	var enableAccessRequests bool
+	var approverGroups string
+	var accessRequestMaxDuration time.Duration
+	var enableBreakGlass bool
+	var breakGlassClusterRole string
+	var breakGlassMaxDuration time.Duration
+	var auditLog string
+	var tracingOpts tracing.Options
+	var stallThreshold time.Duration
+	var inventoryConfigMap string
+	var inventoryInterval time.Duration
+	var dryRun bool
+	var syncPeriod time.Duration
+	var shardID int
+	var shardCount int
+	var shardLeaseNamespace string
+	var shardIdentity string
+	var shardLeaseDuration time.Duration
+	var sharedCustomRoles bool
+	var sharedRoleMinAge time.Duration
+	var sharedRoleGCInterval time.Duration
+	var enableDebugEndpoint bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
+	flag.BoolVar(&enableDebugEndpoint, "enable-debug-endpoint", false,
+		"Serve the permission report at "+debug.PermissionsPath+" on the metrics endpoint. "+
+			"The endpoint is unauthenticated, only enable it while the metrics endpoint is served behind an authenticating proxy.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&policyFile, "policy-file", "",
+		"Path to a YAML file containing CEL policies the requested namespace permissions have to satisfy. "+
+			"If empty, all permissions are allowed.")
+	flag.StringVar(&protectedNamespaces, "protected-namespaces", "kube-*",
+		"Comma separated list of namespace name patterns the controller never grants permissions in.")
+	flag.StringVar(&deniedSubjects, "denied-subjects",
+		"kind=Group;name=system:masters,kind=Group;name=system:unauthenticated,kind=User;name=system:anonymous",
+		"Subjects that must never be bound, in the format of the rolebinding-subjects annotation. "+
+			"Omitted properties match every value, names support shell patterns.")
+	flag.StringVar(&trustedFieldManagers, "trusted-field-managers", "",
+		"Comma separated list of field managers (e.g. argocd-controller) allowed to write the permission annotations. "+
+			"If empty, annotations written by any field manager are honoured.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the admission webhooks validating the permission annotations of namespaces.")
+	flag.BoolVar(&protectManagedRBAC, "protect-managed-rbac", false,
+		"Reject manual updates and deletions of managed Roles and RoleBindings. Requires --enable-webhooks.")
+	flag.StringVar(&controllerServiceAccount, "controller-service-account", "",
+		"The ServiceAccount of the controller in the format <namespace>/<name>. "+
+			"Its changes to managed Roles and RoleBindings are always allowed.")
+	flag.StringVar(&breakGlassGroups, "break-glass-groups", "",
+		"Comma separated list of groups whose members may always change managed Roles and RoleBindings.")
+	flag.BoolVar(&enableAccessRequests, "enable-access-requests", false,
+		"Enable the AccessRequest controller granting temporary access after an approval. Requires the AccessRequest CRD.")
+	flag.StringVar(&approverGroups, "access-request-approver-groups", "",
+		"Comma separated list of groups whose members may approve AccessRequests. Requires --enable-webhooks.")
+	flag.DurationVar(&accessRequestMaxDuration, "access-request-max-duration", 24*time.Hour,
+		"The maximum duration access can be granted for by an AccessRequest. Zero means no limit.")
+	flag.BoolVar(&enableBreakGlass, "enable-break-glass-access", false,
+		"Enable the BreakGlass controller granting emergency access to managed namespaces. Requires the BreakGlass CRD.")
+	flag.StringVar(&breakGlassClusterRole, "break-glass-cluster-role", "admin",
+		"The ClusterRole bound to the subject of a BreakGlass.")
+	flag.DurationVar(&breakGlassMaxDuration, "break-glass-max-duration", time.Hour,
+		"The maximum duration emergency access can be granted for by a BreakGlass. Zero means no limit.")
+	flag.StringVar(&auditLog, "audit-log", "",
+		"Write a JSON-lines record of every permission change to \"stdout\" or append it to the given file. "+
+			"Disabled if empty.")
+	flag.StringVar(&tracingOpts.Exporter, "tracing-exporter", tracing.ExporterNone,
+		"The exporter of the reconciliation traces, one of none, otlp-grpc, otlp-http, stdout or file.")
+	flag.StringVar(&tracingOpts.Endpoint, "tracing-endpoint", "",
+		"The OTLP endpoint traces are sent to. If empty, the OTEL_EXPORTER_OTLP_* environment variables are used.")
+	flag.BoolVar(&tracingOpts.Insecure, "tracing-insecure", false,
+		"Disable TLS for the OTLP exporters.")
+	flag.StringVar(&tracingOpts.File, "tracing-file", "",
+		"The file traces are appended to by the file exporter.")
+	flag.Float64Var(&tracingOpts.SampleRatio, "tracing-sample-ratio", 1,
+		"The fraction of reconciliations to trace, between 0 and 1.")
+	flag.DurationVar(&stallThreshold, "liveness-stall-threshold", 5*time.Minute,
+		"The liveness probe fails once a single reconciliation runs longer than this, "+
+			"or queued requests wait this long without any request being done. Zero disables the check.")
+	flag.StringVar(&inventoryConfigMap, "inventory-configmap", "",
+		"Periodically write the permission inventory of all managed namespaces to this ConfigMap "+
+			"in the format <namespace>/<name>. Disabled if empty.")
+	flag.DurationVar(&inventoryInterval, "inventory-interval", 24*time.Hour,
+		"The interval the permission inventory is written in.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Hour,
+		"The interval all managed namespaces are reconciled in, even if they haven't changed, to correct drift.")
+	flag.IntVar(&shardCount, "shard-count", 0,
+		"Split the managed namespaces into this many static shards, reconciled by --shard-id. Zero disables static sharding.")
+	flag.IntVar(&shardID, "shard-id", 0,
+		"The static shard reconciled by this replica, between 0 and --shard-count - 1.")
+	flag.StringVar(&shardLeaseNamespace, "shard-lease-namespace", "",
+		"Shard the managed namespaces across all live replicas, which announce themselves with a Lease in this namespace. "+
+			"It has to be the namespace of the manager ($POD_NAMESPACE). Disabled if empty.")
+	flag.StringVar(&shardIdentity, "shard-identity", os.Getenv("POD_NAME"),
+		"The identity of the replica in the Lease based sharding. Defaults to $POD_NAME or the hostname.")
+	flag.DurationVar(&shardLeaseDuration, "shard-lease-duration", 15*time.Second,
+		"The time after which a replica that stopped renewing its shard Lease is considered gone.")
+	flag.BoolVar(&sharedCustomRoles, "shared-custom-roles", false,
+		"Deduplicate identical custom rules into ClusterRoles named "+controller.SharedRolePrefix+"<hash>, "+
+			"which the RoleBindings of all namespaces with these rules are bound to, instead of creating a Role per namespace.")
+	flag.DurationVar(&sharedRoleMinAge, "shared-role-min-age", 5*time.Minute,
+		"The minimum age of a shared ClusterRole no RoleBinding is bound to before it is deleted.")
+	flag.DurationVar(&sharedRoleGCInterval, "shared-role-gc-interval", 5*time.Minute,
+		"The interval unused shared ClusterRoles are collected in.")
+	flag.BoolVar(&dryRun, "dry-run", false,
+		"Only plan the changes of Roles, RoleBindings and other objects. All writes are sent as server-side dry-run, "+
+			"planned changes are logged, counted in the planned_operations_total metric and marked in the audit log.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -64,8 +195,26 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	// only cache the namespaces and RBAC objects the controller manages. Namespaces that lost the label
+	// leave the cache, the NamespaceReconciler reads them through the API reader to clean them up.
+	labelled, err := labels.NewRequirement(controller.LabelNamespacePermissionControl, selection.Exists, nil)
+	if err != nil {
+		setupLog.Error(err, "unable to build namespace selector")
+		os.Exit(1)
+	}
+	managed := labels.SelectorFromSet(labels.Set{controller.LabelManagedBy: controller.ManagedByValue})
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                 scheme,
+		Scheme: scheme,
+		Cache: cache.Options{
+			SyncPeriod: &syncPeriod,
+			ByObject: map[client.Object]cache.ByObject{
+				&corev1.Namespace{}:   {Label: labels.NewSelector().Add(*labelled)},
+				&rbacv1.Role{}:        {Label: managed},
+				&rbacv1.RoleBinding{}: {Label: managed},
+				&rbacv1.ClusterRole{}: {Label: managed},
+			},
+		},
 		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
 		LeaderElection:         enableLeaderElection,
@@ -87,23 +236,283 @@ func main() {
 		os.Exit(1)
 	}
 
+	var policies *policy.Evaluator
+	if policyFile != "" {
+		policies, err = policy.LoadFile(policyFile)
+		if err != nil {
+			setupLog.Error(err, "unable to load policies", "file", policyFile)
+			os.Exit(1)
+		}
+		setupLog.Info("loaded policies", "file", policyFile, "count", policies.Len())
+	}
+
+	shutdownTracing, err := tracing.Setup(context.Background(), tracingOpts)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
+
+	auditLogger, auditCloser, err := audit.Open(auditLog)
+	if err != nil {
+		setupLog.Error(err, "unable to set up audit log")
+		os.Exit(1)
+	}
+	defer auditCloser.Close()
+
+	stalls := health.NewStallDetector(stallThreshold)
+
+	guard, err := controller.NewGuard(strc.RemoveEmpty(strc.Array(protectedNamespaces)), deniedSubjects)
+	if err != nil {
+		setupLog.Error(err, "unable to set up namespace and subject guard")
+		os.Exit(1)
+	}
+
+	// without sharding the leader reconciles all namespaces
+	var namespaceShard controller.Shard
+	switch {
+	case shardLeaseNamespace != "" && shardCount > 0:
+		setupLog.Error(nil, "--shard-count and --shard-lease-namespace are mutually exclusive")
+		os.Exit(1)
+	case shardCount > 0:
+		static, err := shard.NewStatic(shardID, shardCount)
+		if err != nil {
+			setupLog.Error(err, "unable to set up sharding")
+			os.Exit(1)
+		}
+		setupLog.Info("reconciling static shard", "shard", shardID, "shards", shardCount)
+		namespaceShard = static
+	case shardLeaseNamespace != "":
+		// the Role granting access to Leases only exists in the namespace of the manager
+		if managerNamespace := os.Getenv("POD_NAMESPACE"); managerNamespace != "" && shardLeaseNamespace != managerNamespace {
+			setupLog.Error(nil, "--shard-lease-namespace has to be the namespace of the manager",
+				"shardLeaseNamespace", shardLeaseNamespace, "managerNamespace", managerNamespace)
+			os.Exit(1)
+		}
+		if shardIdentity == "" {
+			if shardIdentity, err = os.Hostname(); err != nil {
+				setupLog.Error(err, "unable to determine shard identity")
+				os.Exit(1)
+			}
+		}
+		membership := shard.NewLeaseMembership(mgr.GetClient(), mgr.GetAPIReader(), shardLeaseNamespace, shardIdentity, shardLeaseDuration)
+		if err := mgr.Add(membership); err != nil {
+			setupLog.Error(err, "unable to set up sharding")
+			os.Exit(1)
+		}
+		if err := mgr.AddReadyzCheck("shard-membership", membership.Check); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", "shard-membership")
+			os.Exit(1)
+		}
+		setupLog.Info("reconciling lease based shard", "identity", shardIdentity, "namespace", shardLeaseNamespace)
+		namespaceShard = membership
+	}
+
+	// in dry-run mode the api server validates every write but persists none
+	writeClient := mgr.GetClient()
+	if dryRun {
+		setupLog.Info("running in dry-run mode, no changes are persisted")
+		writeClient = client.NewDryRunClient(writeClient)
+	}
+
 	if err = (&controller.NamespaceReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:    writeClient,
+		APIReader: mgr.GetAPIReader(),
+		Scheme:    mgr.GetScheme(),
+		Recorder:  mgr.GetEventRecorderFor("namespace-permission-controller"),
+		Policy:    policies,
+		Guard:     guard,
+		Audit:     auditLogger,
+		Stalls:    stalls,
+		Shard:     namespaceShard,
+		DryRun:    dryRun,
+
+		SharedRoles:          sharedCustomRoles,
+		TrustedFieldManagers: strc.RemoveEmpty(strc.Array(trustedFieldManagers)),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Namespace")
 		os.Exit(1)
 	}
+	// collected even if sharing is disabled as long as shared ClusterRoles are left, so they are removed
+	collectSharedRoles := sharedCustomRoles
+	if !collectSharedRoles {
+		shared := &rbacv1.ClusterRoleList{}
+		err := mgr.GetAPIReader().List(context.Background(), shared, client.Limit(1),
+			client.MatchingLabels{controller.LabelManagedBy: controller.ManagedByValue}, client.HasLabels{controller.LabelSharedRules})
+		if err != nil {
+			setupLog.Error(err, "unable to list shared clusterroles, collecting them anyway")
+		}
+		collectSharedRoles = err != nil || len(shared.Items) > 0
+	}
+	if collectSharedRoles {
+		if err := mgr.Add(&controller.SharedRoleCollector{
+			Client:   writeClient,
+			Interval: sharedRoleGCInterval,
+			MinAge:   sharedRoleMinAge,
+			Audit:    auditLogger,
+			DryRun:   dryRun,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up shared clusterrole collector")
+			os.Exit(1)
+		}
+	}
+	managedNamespaces := controller.NewManagedNamespaceCollector(mgr.GetCache(), guard)
+	managedNamespaces.TrustedFieldManagers = strc.RemoveEmpty(strc.Array(trustedFieldManagers))
+	if err = metrics.Registry.Register(managedNamespaces); err != nil {
+		setupLog.Error(err, "unable to register metrics collector")
+		os.Exit(1)
+	}
+	if enableAccessRequests {
+		// only the webhook makes sure requests are approved by approvers who may bind the requested role
+		if !enableWebhooks {
+			setupLog.Error(nil, "--enable-access-requests requires --enable-webhooks")
+			os.Exit(1)
+		}
+		if err = (&controller.AccessRequestReconciler{
+			Client:      writeClient,
+			APIReader:   mgr.GetAPIReader(),
+			Scheme:      mgr.GetScheme(),
+			Recorder:    mgr.GetEventRecorderFor("access-request-controller"),
+			Guard:       guard,
+			Policy:      policies,
+			MaxDuration: accessRequestMaxDuration,
+			Audit:       auditLogger,
+			Stalls:      stalls,
+			DryRun:      dryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "AccessRequest")
+			os.Exit(1)
+		}
+	}
+
+	if enableBreakGlass {
+		if err = (&controller.BreakGlassReconciler{
+			Client:      writeClient,
+			Scheme:      mgr.GetScheme(),
+			Recorder:    mgr.GetEventRecorderFor("break-glass-controller"),
+			Guard:       guard,
+			ClusterRole: breakGlassClusterRole,
+			MaxDuration: breakGlassMaxDuration,
+			Audit:       auditLogger,
+			Stalls:      stalls,
+			DryRun:      dryRun,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "BreakGlass")
+			os.Exit(1)
+		}
+	}
+
+	if enableWebhooks {
+		if err = (&webhook.NamespaceValidator{
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Guard:     guard,
+			Policy:    policies,
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Namespace")
+			os.Exit(1)
+		}
+		if protectManagedRBAC && controllerServiceAccount == "" {
+			setupLog.Error(nil, "--protect-managed-rbac requires --controller-service-account")
+			os.Exit(1)
+		}
+		var controllerUsername string
+		if controllerServiceAccount != "" {
+			saNamespace, saName, ok := strings.Cut(controllerServiceAccount, "/")
+			if !ok {
+				setupLog.Error(nil, "invalid controller service account, expected <namespace>/<name>",
+					"serviceAccount", controllerServiceAccount)
+				os.Exit(1)
+			}
+			controllerUsername = "system:serviceaccount:" + saNamespace + ":" + saName
+		}
+		if err = (&webhook.ManagedRBACValidator{
+			APIReader:          mgr.GetAPIReader(),
+			Enabled:            protectManagedRBAC,
+			ControllerUsername: controllerUsername,
+			BreakGlassGroups:   strc.RemoveEmpty(strc.Array(breakGlassGroups)),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Role")
+			os.Exit(1)
+		}
+		if enableAccessRequests {
+			if err = (&webhook.AccessRequestValidator{
+				ApproverGroups: strc.RemoveEmpty(strc.Array(approverGroups)),
+				MaxDuration:    accessRequestMaxDuration,
+				Guard:          guard,
+				Client:         mgr.GetClient(),
+				APIReader:      mgr.GetAPIReader(),
+			}).SetupWebhookWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "AccessRequest")
+				os.Exit(1)
+			}
+		}
+		if enableBreakGlass {
+			if err = (&webhook.BreakGlassValidator{
+				MaxDuration: breakGlassMaxDuration,
+			}).SetupWebhookWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create webhook", "webhook", "BreakGlass")
+				os.Exit(1)
+			}
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	// served behind the authenticating proxy of the metrics endpoint
+	if enableDebugEndpoint {
+		if err := mgr.AddMetricsServerExtraHandler(debug.PermissionsPath, &debug.PermissionsHandler{
+			Reader: mgr.GetAPIReader(),
+			Guard:  guard,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up debug handler")
+			os.Exit(1)
+		}
+	}
+
+	if inventoryConfigMap != "" {
+		cmNamespace, cmName, ok := strings.Cut(inventoryConfigMap, "/")
+		if !ok || cmNamespace == "" || cmName == "" || inventoryInterval <= 0 {
+			setupLog.Error(nil, "invalid inventory configuration, expected <namespace>/<name> and a positive interval",
+				"configmap", inventoryConfigMap, "interval", inventoryInterval)
+			os.Exit(1)
+		}
+		if err := mgr.Add(&inventory.ConfigMapWriter{
+			Reader:    mgr.GetAPIReader(),
+			Client:    writeClient,
+			Guard:     guard,
+			Namespace: cmNamespace,
+			Name:      cmName,
+			Interval:  inventoryInterval,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up inventory")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
-		setupLog.Error(err, "unable to set up ready check")
+	if err := mgr.AddHealthzCheck("reconcile-stall", stalls.Check); err != nil {
+		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
+	readyChecks := map[string]healthz.Checker{
+		"readyz":         healthz.Ping,
+		"cache-sync":     health.CacheSynced(mgr.GetCache()),
+		"rbac-api":       health.RBACReachable(mgr.GetAPIReader()),
+		"rbac-selfcheck": (&health.SelfCheck{Client: mgr.GetClient(), SharedRoles: sharedCustomRoles}).Check,
+	}
+	for name, check := range readyChecks {
+		if err := mgr.AddReadyzCheck(name, check); err != nil {
+			setupLog.Error(err, "unable to set up ready check", "check", name)
+			os.Exit(1)
+		}
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
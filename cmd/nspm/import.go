@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/cli"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/importer"
+	strc "github.com/tagesspiegel/kubernetes-namespace-permission-manager/utils/strings"
+)
+
+const (
+	importFormatYAML = "yaml"
+	importFormatJSON = "json"
+)
+
+func runImport(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	kube := cli.AddKubeFlags(fs)
+	var namespace, output, protectedNamespaces string
+	var all, relabel bool
+	fs.StringVar(&namespace, "namespace", "", "The namespace to import.")
+	fs.BoolVar(&all, "all", false, "Import all namespaces not yet managed by the controller.")
+	fs.StringVar(&protectedNamespaces, "protected-namespaces", "kube-*",
+		"Comma separated list of namespace patterns skipped by --all.")
+	fs.BoolVar(&relabel, "relabel", false, "Label the existing Role and RoleBinding named like the namespace as managed, "+
+		"so the controller adopts them. Namespaces with unsupported permissions are not relabeled.")
+	fs.StringVar(&output, "o", importFormatYAML, "Output format, yaml prints merge patches, json the full import report.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: nspm import [flags] (--namespace <name> | --all)")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Converts the existing Roles and RoleBindings of namespaces into permission annotations")
+		fmt.Fprintln(stderr, "and prints them as merge patches of the namespaces. Permissions that can't be expressed")
+		fmt.Fprintln(stderr, "are reported on stderr.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if (namespace == "") == !all {
+		fmt.Fprintln(stderr, "either --namespace or --all is required")
+		return exitUsage
+	}
+	if output != importFormatYAML && output != importFormatJSON {
+		fmt.Fprintf(stderr, "unknown format %q\n", output)
+		return exitUsage
+	}
+	guard, err := controller.NewGuard(strc.RemoveEmpty(strc.Array(protectedNamespaces)), "")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	c, err := kube.Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to create client: %s\n", err)
+		return exitUsage
+	}
+
+	ctx := context.Background()
+	namespaces := []string{namespace}
+	if all {
+		if namespaces, err = importableNamespaces(ctx, c, guard); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+
+	code := exitOK
+	results := []importer.Result{}
+	for _, ns := range namespaces {
+		r, err := importer.Namespace(ctx, c, ns)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		for _, f := range r.Unsupported {
+			fmt.Fprintf(stderr, "%s: unsupported: %s\n", ns, f)
+			code = exitFindings
+		}
+		for _, l := range r.Leftovers {
+			fmt.Fprintf(stderr, "%s: %s is not adopted, delete it once the controller manages the namespace\n", ns, l)
+		}
+		if len(r.Annotations) == 0 {
+			fmt.Fprintf(stderr, "%s: no RoleBindings to import\n", ns)
+			continue
+		}
+		if relabel {
+			if len(r.Unsupported) > 0 {
+				fmt.Fprintf(stderr, "%s: not relabeling, the import is incomplete\n", ns)
+			} else if err := importer.Relabel(ctx, c, r); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitUsage
+			} else {
+				for _, obj := range r.Adopt {
+					fmt.Fprintf(stderr, "%s: relabeled %s\n", ns, importer.ObjectName(obj))
+				}
+			}
+		}
+		results = append(results, r)
+	}
+
+	if output == importFormatJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+		return code
+	}
+	for _, r := range results {
+		if err := writeDocument(stdout, r.Patch()); err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitUsage
+		}
+	}
+	return code
+}
+
+// importableNamespaces returns all namespaces that are neither protected nor managed yet
+func importableNamespaces(ctx context.Context, c client.Client, guard *controller.Guard) ([]string, error) {
+	list := &corev1.NamespaceList{}
+	if err := c.List(ctx, list); err != nil {
+		return nil, fmt.Errorf("unable to list namespaces: %w", err)
+	}
+	var names []string
+	for _, ns := range list.Items {
+		if _, managed := ns.Labels[controller.LabelNamespacePermissionControl]; managed || guard.IsProtectedNamespace(ns.Name) {
+			continue
+		}
+		names = append(names, ns.Name)
+	}
+	return names, nil
+}
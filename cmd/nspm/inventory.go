@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/cli"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/inventory"
+	strc "github.com/tagesspiegel/kubernetes-namespace-permission-manager/utils/strings"
+)
+
+func runInventory(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("inventory", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	kube := cli.AddKubeFlags(fs)
+	var output, namespace, protectedNamespaces string
+	var failOnMismatch bool
+	fs.StringVar(&output, "o", inventory.FormatCSV, "Output format, one of "+strings.Join(inventory.Formats, ", ")+".")
+	fs.StringVar(&namespace, "namespace", "", "Only report this namespace.")
+	fs.StringVar(&protectedNamespaces, "protected-namespaces", "kube-*",
+		"Comma separated list of namespace patterns left out of the report, like the controller ignores them.")
+	fs.BoolVar(&failOnMismatch, "fail-on-mismatch", false, "Exit with 1 if a namespace is not in sync.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: nspm inventory [flags]")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Reports the parsed permission annotations and the live Role and RoleBinding")
+		fmt.Fprintln(stderr, "of every managed namespace, including all mismatches.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if !inventory.ValidFormat(output) {
+		fmt.Fprintf(stderr, "unknown format %q\n", output)
+		return exitUsage
+	}
+	guard, err := controller.NewGuard(strc.RemoveEmpty(strc.Array(protectedNamespaces)), "")
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	c, err := kube.Client()
+	if err != nil {
+		fmt.Fprintf(stderr, "unable to create client: %s\n", err)
+		return exitUsage
+	}
+
+	now := time.Now()
+	namespaces, err := inventory.Collect(context.Background(), c, guard, namespace, now)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if err := inventory.Write(stdout, output, inventory.Report{GeneratedAt: now.UTC(), Namespaces: namespaces}); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if failOnMismatch {
+		for _, p := range namespaces {
+			if !p.InSync {
+				return exitFindings
+			}
+		}
+	}
+	return exitOK
+}
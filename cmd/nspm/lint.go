@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/lint"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/manifest"
+)
+
+func runLint(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lint", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	files := addFilenameFlag(fs)
+	var output string
+	var warningsAsErrors bool
+	fs.StringVar(&output, "o", lint.FormatText, "Output format, one of text, json or junit.")
+	fs.BoolVar(&warningsAsErrors, "warnings-as-errors", false, "Fail if any warning is found.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: nspm lint [flags] [-f] [file|directory|-]...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Validates the permission annotations of all Namespaces in the given manifests.")
+		fmt.Fprintln(stderr, "Directories are searched for .yaml, .yml and .json files, - reads from stdin.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	namespaces, err := manifest.ReadNamespaces(manifestPaths(fs, files), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	now := time.Now()
+	failed := false
+	results := make([]lint.Result, 0, len(namespaces))
+	for _, ns := range namespaces {
+		r := lint.Result{
+			Source:    ns.Source,
+			Document:  ns.Document,
+			Namespace: ns.Namespace.Name,
+			Findings:  lint.Namespace(ns.Namespace, now),
+		}
+		if r.Count(lint.SeverityError) > 0 || (warningsAsErrors && r.Count(lint.SeverityWarning) > 0) {
+			failed = true
+		}
+		results = append(results, r)
+	}
+	if err := lint.Write(stdout, output, results); err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	if failed {
+		return exitFindings
+	}
+	return exitOK
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// nspm is the command line companion of the namespace permission manager
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/manifest"
+)
+
+// exit codes of all commands
+const (
+	exitOK       = 0
+	exitFindings = 1
+	exitUsage    = 2
+)
+
+type command struct {
+	name  string
+	short string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = []command{
+	{name: "import", short: "Convert existing Roles and RoleBindings into permission annotations", run: runImport},
+	{name: "inventory", short: "Report the permissions of all managed namespaces as CSV, JSON or Markdown", run: runInventory},
+	{name: "lint", short: "Validate the permission annotations of Namespace manifests", run: runLint},
+	{name: "render", short: "Print the Roles and RoleBindings the controller creates for Namespace manifests", run: runRender},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		return exitUsage
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:], stdin, stdout, stderr)
+		}
+	}
+	fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+	usage(stderr)
+	return exitUsage
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: nspm <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.short)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'nspm <command> -h' for the flags of a command.")
+}
+
+// filenames collects the manifests given with the repeatable -f flag
+type filenames []string
+
+func (f *filenames) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *filenames) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+// addFilenameFlag registers -f and --filename on the flag set
+func addFilenameFlag(fs *flag.FlagSet) *filenames {
+	f := &filenames{}
+	fs.Var(f, "f", "File or directory with Namespace manifests, - reads from stdin. Can be repeated.")
+	fs.Var(f, "filename", "Alias of -f.")
+	return f
+}
+
+// manifestPaths returns the paths given with -f and as arguments. Without any, stdin is read.
+func manifestPaths(fs *flag.FlagSet, f *filenames) []string {
+	paths := append([]string{}, *f...)
+	paths = append(paths, fs.Args()...)
+	if len(paths) == 0 {
+		paths = []string{manifest.Stdin}
+	}
+	return paths
+}
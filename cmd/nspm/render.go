@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/manifest"
+)
+
+func runRender(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	files := addFilenameFlag(fs)
+	var at string
+	fs.StringVar(&at, "at", "", "RFC 3339 point in time expiring subjects are evaluated at. Defaults to now.")
+	var shared bool
+	fs.BoolVar(&shared, "shared-custom-roles", false,
+		"Render the shared ClusterRoles of a controller running with --shared-custom-roles instead of a Role per namespace.")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: nspm render [flags] [-f] [file|directory|-]...")
+		fmt.Fprintln(stderr)
+		fmt.Fprintln(stderr, "Prints the Roles and RoleBindings the controller creates for all managed Namespaces")
+		fmt.Fprintln(stderr, "in the given manifests as YAML.")
+		fmt.Fprintln(stderr)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	now := time.Now()
+	if at != "" {
+		var err error
+		if now, err = time.Parse(time.RFC3339, at); err != nil {
+			fmt.Fprintf(stderr, "invalid --at: %s\n", err)
+			return exitUsage
+		}
+	}
+
+	namespaces, err := manifest.ReadNamespaces(manifestPaths(fs, files), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+	code := exitOK
+	// every shared ClusterRole is rendered once
+	rendered := map[string]bool{}
+	for _, ns := range namespaces {
+		if _, ok := ns.Namespace.Labels[controller.LabelNamespacePermissionControl]; !ok {
+			fmt.Fprintf(stderr, "%s[%d]: skipping namespace %q without the %s label\n",
+				ns.Source, ns.Document, ns.Namespace.Name, controller.LabelNamespacePermissionControl)
+			continue
+		}
+		desired, err := controller.Desired(ns.Namespace, now)
+		if err != nil {
+			fmt.Fprintf(stderr, "%s[%d]: namespace %q: %s\n", ns.Source, ns.Document, ns.Namespace.Name, err)
+			code = exitFindings
+			continue
+		}
+		if shared {
+			desired.ShareRole()
+		}
+		var objects []any
+		if desired.ClusterRole != nil && !rendered[desired.ClusterRole.Name] {
+			rendered[desired.ClusterRole.Name] = true
+			objects = append(objects, desired.ClusterRole)
+		}
+		if desired.Role != nil {
+			objects = append(objects, desired.Role)
+		}
+		if desired.RoleBinding != nil {
+			objects = append(objects, desired.RoleBinding)
+		}
+		for _, obj := range objects {
+			if err := writeDocument(stdout, obj); err != nil {
+				fmt.Fprintln(stderr, err)
+				return exitUsage
+			}
+		}
+	}
+	return code
+}
+
+// writeDocument writes obj as a single YAML document
+func writeDocument(w io.Writer, obj any) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "---\n%s", data)
+	return err
+}
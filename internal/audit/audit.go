@@ -0,0 +1,141 @@
+// Package audit writes an append-only JSON-lines record of every permission change made by the controller.
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	// SinkStdout writes the audit records to stdout
+	SinkStdout = "stdout"
+)
+
+// Operation is the change applied to a Role or RoleBinding
+type Operation string
+
+const (
+	OperationCreated Operation = "created"
+	OperationUpdated Operation = "updated"
+	OperationDeleted Operation = "deleted"
+)
+
+// Origin describes what triggered a permission change
+type Origin struct {
+	// Kind and Name identify the object the permissions are derived from, e.g. a Namespace or an AccessRequest
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// AnnotationHash is the hash of the permission annotations of a namespace
+	AnnotationHash string `json:"annotationHash,omitempty"`
+	// FieldManager is the field manager that last changed the permission annotations of a namespace
+	FieldManager string `json:"fieldManager,omitempty"`
+}
+
+// State holds the permissions of a Role or RoleBinding
+type State struct {
+	Subjects []rbacv1.Subject    `json:"subjects,omitempty"`
+	RoleRef  *rbacv1.RoleRef     `json:"roleRef,omitempty"`
+	Rules    []rbacv1.PolicyRule `json:"rules,omitempty"`
+}
+
+// Diff lists the permissions added and removed by a change
+type Diff struct {
+	AddedSubjects   []rbacv1.Subject    `json:"addedSubjects,omitempty"`
+	RemovedSubjects []rbacv1.Subject    `json:"removedSubjects,omitempty"`
+	AddedRules      []rbacv1.PolicyRule `json:"addedRules,omitempty"`
+	RemovedRules    []rbacv1.PolicyRule `json:"removedRules,omitempty"`
+	RoleRefChanged  bool                `json:"roleRefChanged,omitempty"`
+}
+
+// Record is a single line of the audit stream
+type Record struct {
+	Time      time.Time `json:"time"`
+	Operation Operation `json:"operation"`
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	Origin    Origin    `json:"origin"`
+	Before    *State    `json:"before,omitempty"`
+	After     *State    `json:"after,omitempty"`
+	Diff      Diff      `json:"diff"`
+	// DryRun marks a change that was only planned and never applied
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// Logger writes audit records as JSON lines. A nil Logger discards all records.
+type Logger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// New returns a Logger writing to w
+func New(w io.Writer) *Logger {
+	return &Logger{enc: json.NewEncoder(w), now: time.Now}
+}
+
+// Open returns a Logger for the given sink, which is either SinkStdout or the path of a file records are appended to.
+// An empty sink disables the audit log and returns a nil Logger. The returned closer has to be closed on shutdown.
+func Open(sink string) (*Logger, io.Closer, error) {
+	switch sink {
+	case "":
+		return nil, io.NopCloser(nil), nil
+	case SinkStdout:
+		return New(os.Stdout), io.NopCloser(nil), nil
+	}
+	f, err := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to open audit log: %w", err)
+	}
+	return New(f), f, nil
+}
+
+// Log completes the record with the time and diff of the change and writes it
+func (l *Logger) Log(r Record) error {
+	if l == nil {
+		return nil
+	}
+	r.Diff = Compare(r.Before, r.After)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r.Time.IsZero() {
+		r.Time = l.now().UTC()
+	}
+	return l.enc.Encode(r)
+}
+
+// Compare returns the permissions added and removed between before and after. Both may be nil.
+func Compare(before, after *State) Diff {
+	if before == nil {
+		before = &State{}
+	}
+	if after == nil {
+		after = &State{}
+	}
+	return Diff{
+		AddedSubjects:   missing(after.Subjects, before.Subjects),
+		RemovedSubjects: missing(before.Subjects, after.Subjects),
+		AddedRules:      missing(after.Rules, before.Rules),
+		RemovedRules:    missing(before.Rules, after.Rules),
+		RoleRefChanged:  !equality.Semantic.DeepEqual(before.RoleRef, after.RoleRef),
+	}
+}
+
+// missing returns all items of a that are not part of b
+func missing[T any](a, b []T) []T {
+	var out []T
+	for _, item := range a {
+		if !slices.ContainsFunc(b, func(other T) bool { return equality.Semantic.DeepEqual(item, other) }) {
+			out = append(out, item)
+		}
+	}
+	return out
+}
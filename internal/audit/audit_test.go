@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestCompare(t *testing.T) {
+	jane := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane"}
+	john := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "john"}
+	get := rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	edit := rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit"}
+	view := rbacv1.RoleRef{Kind: "ClusterRole", Name: "view"}
+	tests := []struct {
+		name   string
+		before *State
+		after  *State
+		want   Diff
+	}{
+		{
+			name:   "created",
+			before: nil,
+			after:  &State{Subjects: []rbacv1.Subject{jane}, RoleRef: &edit},
+			want:   Diff{AddedSubjects: []rbacv1.Subject{jane}, RoleRefChanged: true},
+		},
+		{
+			name:   "subject replaced",
+			before: &State{Subjects: []rbacv1.Subject{jane}, RoleRef: &edit},
+			after:  &State{Subjects: []rbacv1.Subject{john}, RoleRef: &edit},
+			want:   Diff{AddedSubjects: []rbacv1.Subject{john}, RemovedSubjects: []rbacv1.Subject{jane}},
+		},
+		{
+			name:   "role ref changed",
+			before: &State{Subjects: []rbacv1.Subject{jane}, RoleRef: &edit},
+			after:  &State{Subjects: []rbacv1.Subject{jane}, RoleRef: &view},
+			want:   Diff{RoleRefChanged: true},
+		},
+		{
+			name:   "rules deleted",
+			before: &State{Rules: []rbacv1.PolicyRule{get}},
+			after:  nil,
+			want:   Diff{RemovedRules: []rbacv1.PolicyRule{get}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, Compare(tt.before, tt.after)); diff != "" {
+				t.Errorf("Compare() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestLogger_Log(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf)
+	l.now = func() time.Time { return time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC) }
+	jane := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane"}
+	for range 2 {
+		err := l.Log(Record{
+			Operation: OperationCreated,
+			Kind:      "RoleBinding",
+			Namespace: "test",
+			Name:      "test",
+			Origin:    Origin{Kind: "Namespace", Name: "test", FieldManager: "kubectl"},
+			After:     &State{Subjects: []rbacv1.Subject{jane}},
+		})
+		if err != nil {
+			t.Fatalf("Logger.Log() error = %v", err)
+		}
+	}
+
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	if len(lines) != 2 {
+		t.Fatalf("Logger.Log() wrote %d lines, want 2", len(lines))
+	}
+	got := Record{}
+	if err := json.Unmarshal(lines[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Record{
+		Time:      l.now(),
+		Operation: OperationCreated,
+		Kind:      "RoleBinding",
+		Namespace: "test",
+		Name:      "test",
+		Origin:    Origin{Kind: "Namespace", Name: "test", FieldManager: "kubectl"},
+		After:     &State{Subjects: []rbacv1.Subject{jane}},
+		Diff:      Diff{AddedSubjects: []rbacv1.Subject{jane}},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("Logger.Log() mismatch (-want +got):\n%s", diff)
+	}
+
+	// a nil logger discards all records
+	var nilLogger *Logger
+	if err := nilLogger.Log(Record{}); err != nil {
+		t.Errorf("Logger.Log() on nil logger error = %v", err)
+	}
+}
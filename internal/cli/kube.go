@@ -0,0 +1,41 @@
+// Package cli holds helpers shared by the command line tools.
+package cli
+
+import (
+	"flag"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubeFlags select the cluster of commands talking to the API server
+type KubeFlags struct {
+	Kubeconfig string
+	Context    string
+}
+
+// AddKubeFlags registers --kubeconfig and --context on the flag set
+func AddKubeFlags(fs *flag.FlagSet) *KubeFlags {
+	k := &KubeFlags{}
+	fs.StringVar(&k.Kubeconfig, "kubeconfig", "", "Path to the kubeconfig file. Defaults to $KUBECONFIG or ~/.kube/config.")
+	fs.StringVar(&k.Context, "context", "", "The kubeconfig context to use.")
+	return k
+}
+
+// Client returns a client for the selected cluster
+func (k *KubeFlags) Client() (client.Client, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = k.Kubeconfig
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules,
+		&clientcmd.ConfigOverrides{CurrentContext: k.Context}).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return client.New(config, client.Options{Scheme: scheme})
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/health"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/policy"
+)
+
+const (
+	// LabelAccessRequest is set on RoleBindings created for an AccessRequest
+	LabelAccessRequest = "ns.tagesspiegel.de/access-request"
+
+	EventReasonAccessGranted = "AccessGranted"
+	EventReasonAccessDenied  = "AccessDenied"
+)
+
+// AccessRequestReconciler materialises approved AccessRequests as RoleBindings
+// and removes them again once the requested duration ran out
+type AccessRequestReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Guard protects namespaces and subjects from ever being managed.
+	// A nil Guard protects nothing.
+	Guard *Guard
+	// Policy is evaluated against the namespace, subject and role of every approved request.
+	// A nil Policy allows all requests.
+	Policy *policy.Evaluator
+	// APIReader reads the namespace of a request for the Policy, as the cache only holds labelled namespaces.
+	// A nil APIReader relies on the Client.
+	APIReader client.Reader
+	// MaxDuration caps the duration of every request. Zero means no limit.
+	MaxDuration time.Duration
+	// Audit records every change of a RoleBinding. A nil Audit records nothing.
+	Audit *audit.Logger
+	// Stalls tracks running reconciliations for the liveness check. A nil Stalls tracks nothing.
+	Stalls *health.StallDetector
+	// DryRun only plans changes. The Client is expected to be a dry-run client, so no change is persisted.
+	DryRun bool
+}
+
+//+kubebuilder:rbac:groups=ns.tagesspiegel.de,resources=accessrequests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ns.tagesspiegel.de,resources=accessrequests/status,verbs=get;update;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *AccessRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Stalls.Begin()()
+	logx := log.FromContext(ctx)
+
+	ar := &nsv1alpha1.AccessRequest{}
+	if err := r.Client.Get(ctx, req.NamespacedName, ar); err != nil {
+		if errors.IsNotFound(err) {
+			// the RoleBinding is garbage collected through its owner reference
+			logx.V(100).Info("access request not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		logx.Error(err, "unable to fetch AccessRequest")
+		return ctrl.Result{}, err
+	}
+
+	rbName := "access-request-" + ar.Name
+	origin := audit.Origin{Kind: "AccessRequest", Name: ar.Name}
+	status := ar.Status.DeepCopy()
+	status.RoleBindingName = rbName
+
+	var result ctrl.Result
+	switch {
+	case ar.Spec.Approval == nil:
+		status.Phase = nsv1alpha1.AccessRequestPending
+	case !ar.Spec.Approval.Approved:
+		if status.Phase != nsv1alpha1.AccessRequestDenied {
+			r.Recorder.Eventf(ar, corev1.EventTypeNormal, EventReasonAccessDenied, "access denied by %s", ar.Spec.Approval.Approver)
+		}
+		status.Phase = nsv1alpha1.AccessRequestDenied
+		if err := deleteRoleBinding(ctx, r.Client, r.changes(), origin, ar.Namespace, rbName); err != nil {
+			logx.Error(err, "unable to delete rolebinding")
+			return ctrl.Result{}, err
+		}
+	default:
+		now := time.Now()
+		// an approval time in the future would postpone the expiry
+		if status.ApprovedAt == nil || status.ApprovedAt.After(now) {
+			status.ApprovedAt = &metav1.Time{Time: now}
+		}
+		// the expiry is recomputed every time, so extending it in the status doesn't extend the access
+		duration := ar.Spec.Duration.Duration
+		if r.MaxDuration > 0 && duration > r.MaxDuration {
+			duration = r.MaxDuration
+		}
+		status.ExpiresAt = &metav1.Time{Time: status.ApprovedAt.Add(duration)}
+		if !now.Before(status.ExpiresAt.Time) {
+			if status.Phase != nsv1alpha1.AccessRequestExpired {
+				r.Recorder.Eventf(ar, corev1.EventTypeNormal, EventReasonAccessExpired,
+					"access of %s %q expired", ar.Spec.Subject.Kind, ar.Spec.Subject.Name)
+			}
+			status.Phase = nsv1alpha1.AccessRequestExpired
+			if err := deleteRoleBinding(ctx, r.Client, r.changes(), origin, ar.Namespace, rbName); err != nil {
+				logx.Error(err, "unable to delete rolebinding")
+				return ctrl.Result{}, err
+			}
+			break
+		}
+		if r.Guard.IsProtectedNamespace(ar.Namespace) {
+			logx.Info("namespace is protected, ignoring")
+			return ctrl.Result{}, nil
+		}
+		if err := r.Guard.CheckSubjects([]rbacv1.Subject{ar.Spec.Subject}); err != nil {
+			logx.Error(err, "subject rejected")
+			r.Recorder.Event(ar, corev1.EventTypeWarning, EventReasonSubjectDenied, err.Error())
+			return ctrl.Result{}, nil
+		}
+		if denied, err := r.evaluatePolicy(ctx, ar); err != nil || denied {
+			return ctrl.Result{}, err
+		}
+		_, rslt, err := applyRoleBinding(ctx, r.Client, r.Scheme, r.changes(), RoleBindingSpec{
+			Name:      rbName,
+			Namespace: ar.Namespace,
+			RoleRef:   ar.Spec.RoleRef,
+			Subjects:  []rbacv1.Subject{ar.Spec.Subject},
+			Labels:    map[string]string{LabelAccessRequest: ar.Name},
+			Owner:     ar,
+			Origin:    origin,
+		})
+		if err != nil {
+			logx.Error(err, "unable to apply rolebinding")
+			return ctrl.Result{}, err
+		}
+		logx.V(80).Info("result for reconciliation for role binding", "result", rslt)
+		if status.Phase != nsv1alpha1.AccessRequestActive {
+			r.Recorder.Eventf(ar, corev1.EventTypeNormal, EventReasonAccessGranted,
+				"access granted to %s %q until %s", ar.Spec.Subject.Kind, ar.Spec.Subject.Name, status.ExpiresAt.Format(time.RFC3339))
+		}
+		status.Phase = nsv1alpha1.AccessRequestActive
+		// come back once the access expires
+		result.RequeueAfter = time.Until(status.ExpiresAt.Time)
+	}
+
+	if equality.Semantic.DeepEqual(ar.Status, *status) {
+		return result, nil
+	}
+	ar.Status = *status
+	if err := r.Status().Update(ctx, ar); err != nil {
+		logx.Error(err, "unable to update access request status")
+		return ctrl.Result{}, err
+	}
+	return result, nil
+}
+
+// evaluatePolicy checks the requested access against the Policy and reports whether it was denied
+func (r *AccessRequestReconciler) evaluatePolicy(ctx context.Context, ar *nsv1alpha1.AccessRequest) (bool, error) {
+	var reader client.Reader = r.Client
+	if r.APIReader != nil {
+		reader = r.APIReader
+	}
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: ar.Namespace}, ns); err != nil {
+		log.FromContext(ctx).Error(err, "unable to fetch Namespace")
+		return false, err
+	}
+	err := r.Policy.Evaluate(policy.Input{
+		Namespace: ns,
+		Subjects:  []rbacv1.Subject{ar.Spec.Subject},
+		RoleRef:   ar.Spec.RoleRef,
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "access rejected by policy")
+		r.Recorder.Event(ar, corev1.EventTypeWarning, EventReasonPolicyDenied, err.Error())
+		return true, nil
+	}
+	return false, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *AccessRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nsv1alpha1.AccessRequest{}).
+		Owns(&rbacv1.RoleBinding{}).
+		WithOptions(stallOptions(r.Stalls)).
+		Complete(r)
+}
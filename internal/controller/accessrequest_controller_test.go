@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/policy"
+)
+
+func TestAccessRequestReconciler_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsv1alpha1.AddToScheme(scheme)
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "test"}}
+	accessRequest := func(subject rbacv1.Subject, roleRef string) *nsv1alpha1.AccessRequest {
+		return &nsv1alpha1.AccessRequest{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "test"},
+			Spec: nsv1alpha1.AccessRequestSpec{
+				Subject:  subject,
+				RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: roleRef},
+				Duration: metav1.Duration{Duration: time.Hour},
+				Approval: &nsv1alpha1.AccessRequestApproval{Approved: true, Approver: "jane"},
+			},
+		}
+	}
+	john := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "john", APIGroup: rbacv1.GroupName}
+	// approved two hours ago, with an expiry extended by someone allowed to update the status
+	extended := accessRequest(john, "edit")
+	extended.Status = nsv1alpha1.AccessRequestStatus{
+		Phase:      nsv1alpha1.AccessRequestActive,
+		ApprovedAt: &metav1.Time{Time: time.Now().Add(-2 * time.Hour)},
+		ExpiresAt:  &metav1.Time{Time: time.Now().Add(24 * time.Hour)},
+	}
+	policies, err := policy.New([]policy.Policy{{
+		Name:       "no-cluster-admin",
+		Expression: `roleRef.name != "cluster-admin"`,
+		Message:    "cluster-admin can't be requested",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		obj         *nsv1alpha1.AccessRequest
+		wantPhase   nsv1alpha1.AccessRequestPhase
+		wantBinding bool
+	}{
+		{
+			name:        "grants approved access",
+			obj:         accessRequest(john, "edit"),
+			wantPhase:   nsv1alpha1.AccessRequestActive,
+			wantBinding: true,
+		},
+		{
+			name: "rejects role denied by policy",
+			obj:  accessRequest(john, "cluster-admin"),
+		},
+		{
+			name:      "ignores an extended expiry",
+			obj:       extended,
+			wantPhase: nsv1alpha1.AccessRequestExpired,
+		},
+		{
+			name: "rejects denied subject",
+			obj:  accessRequest(rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:masters", APIGroup: rbacv1.GroupName}, "edit"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(ns, tt.obj).
+				WithStatusSubresource(&nsv1alpha1.AccessRequest{}).
+				WithInterceptorFuncs(fakeApply(nil, false)).
+				Build()
+			guard, err := NewGuard(nil, "kind=Group;name=system:masters")
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := &AccessRequestReconciler{
+				Client:   c,
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+				Guard:    guard,
+				Policy:   policies,
+			}
+			ctx := context.Background()
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.obj)}); err != nil {
+				t.Fatalf("AccessRequestReconciler.Reconcile() error = %v", err)
+			}
+			ar := &nsv1alpha1.AccessRequest{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(tt.obj), ar); err != nil {
+				t.Fatal(err)
+			}
+			if ar.Status.Phase != tt.wantPhase {
+				t.Errorf("AccessRequestReconciler.Reconcile() phase = %v, want %v", ar.Status.Phase, tt.wantPhase)
+			}
+			err = c.Get(ctx, client.ObjectKey{Namespace: "test", Name: "access-request-debug"}, &rbacv1.RoleBinding{})
+			if tt.wantBinding != !errors.IsNotFound(err) {
+				t.Errorf("AccessRequestReconciler.Reconcile() rolebinding exists = %v, want %v", err == nil, tt.wantBinding)
+			}
+		})
+	}
+}
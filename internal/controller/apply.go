@@ -0,0 +1,77 @@
+package controller
+
+import (
+	"context"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// FieldManager is the field manager the controller applies all Roles and RoleBindings with
+const FieldManager = "namespace-permission-manager"
+
+// applyObject server-side applies obj, which must only hold the fields owned by the controller.
+// exists tells whether the live object exists. The write is skipped if it is already upToDate.
+//
+// A conflict means other field managers changed fields the controller owns, e.g. by editing the
+// object manually. The controller is the source of truth for them, so it takes the fields over.
+func applyObject(ctx context.Context, c client.Client, kind string, obj client.Object, exists, upToDate bool) (controllerutil.OperationResult, error) {
+	if exists && upToDate {
+		return controllerutil.OperationResultNone, nil
+	}
+	err := c.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager))
+	if apierrors.IsConflict(err) {
+		log.FromContext(ctx).Info("taking over fields owned by other field managers",
+			"kind", kind, "namespace", obj.GetNamespace(), "name", obj.GetName(), "conflict", err.Error())
+		recordApplyConflict(kind)
+		err = c.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+	}
+	if err != nil {
+		return controllerutil.OperationResultNone, err
+	}
+	if !exists {
+		return controllerutil.OperationResultCreated, nil
+	}
+	return controllerutil.OperationResultUpdated, nil
+}
+
+// getExisting reads the live object into obj and reports whether it exists
+func getExisting(ctx context.Context, c client.Client, key client.ObjectKey, obj client.Object) (bool, error) {
+	if err := c.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// hasLabels reports whether obj carries all the given labels
+func hasLabels(obj client.Object, labels map[string]string) bool {
+	for k, v := range labels {
+		if value, ok := obj.GetLabels()[k]; !ok || value != v {
+			return false
+		}
+	}
+	return true
+}
+
+// subjectsEqual compares subjects like the API server stores them, which defaults the api group of users and groups
+func subjectsEqual(a, b []rbacv1.Subject) bool {
+	return equality.Semantic.DeepEqual(defaultSubjects(a), defaultSubjects(b))
+}
+
+func defaultSubjects(subjects []rbacv1.Subject) []rbacv1.Subject {
+	defaulted := make([]rbacv1.Subject, 0, len(subjects))
+	for _, s := range subjects {
+		if s.APIGroup == "" && (s.Kind == rbacv1.UserKind || s.Kind == rbacv1.GroupKind) {
+			s.APIGroup = rbacv1.GroupName
+		}
+		defaulted = append(defaulted, s)
+	}
+	return defaulted
+}
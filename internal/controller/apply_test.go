@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// fakeApply emulates server-side apply, which the fake client doesn't support, by creating or replacing the object.
+// It counts the apply requests in applies. If conflict is set, every apply without forced ownership fails with a conflict.
+func fakeApply(applies *int, conflict bool) interceptor.Funcs {
+	return interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			if patch.Type() != types.ApplyPatchType {
+				return c.Patch(ctx, obj, patch, opts...)
+			}
+			if applies != nil {
+				*applies++
+			}
+			po := &client.PatchOptions{}
+			po.ApplyOptions(opts)
+			if conflict && (po.Force == nil || !*po.Force) {
+				return apierrors.NewConflict(schema.GroupResource{Group: rbacv1.GroupName, Resource: "rolebindings"}, obj.GetName(), nil)
+			}
+			existing := obj.DeepCopyObject().(client.Object)
+			err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing)
+			if apierrors.IsNotFound(err) {
+				return c.Create(ctx, obj, &client.CreateOptions{DryRun: po.DryRun, FieldManager: po.FieldManager})
+			}
+			if err != nil {
+				return err
+			}
+			// like the API server, refuse to change the immutable roleRef of a RoleBinding
+			if rb, ok := existing.(*rbacv1.RoleBinding); ok && rb.RoleRef != obj.(*rbacv1.RoleBinding).RoleRef {
+				return apierrors.NewInvalid(rbacv1.SchemeGroupVersion.WithKind("RoleBinding").GroupKind(), obj.GetName(),
+					field.ErrorList{field.Invalid(field.NewPath("roleRef"), obj.(*rbacv1.RoleBinding).RoleRef, "cannot change roleRef")})
+			}
+			obj.SetResourceVersion(existing.GetResourceVersion())
+			return c.Update(ctx, obj, &client.UpdateOptions{DryRun: po.DryRun, FieldManager: po.FieldManager})
+		},
+	}
+}
+
+func TestApplyRoleBinding(t *testing.T) {
+	spec := RoleBindingSpec{
+		Name:      "team",
+		Namespace: "team",
+		RoleRef:   rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+		Subjects:  []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "jane"}},
+	}
+	existing := func(subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+			RoleRef:    spec.RoleRef,
+			Subjects:   subjects,
+		}
+	}
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		conflict    bool
+		want        controllerutil.OperationResult
+		wantApplies int
+	}{
+		{
+			name:        "creates missing rolebinding",
+			want:        controllerutil.OperationResultCreated,
+			wantApplies: 1,
+		},
+		{
+			name: "skips up to date rolebinding with defaulted api group",
+			objs: []client.Object{existing(rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane", APIGroup: rbacv1.GroupName})},
+			want: controllerutil.OperationResultNone,
+		},
+		{
+			name:        "updates changed subjects",
+			objs:        []client.Object{existing(rbacv1.Subject{Kind: rbacv1.UserKind, Name: "john", APIGroup: rbacv1.GroupName})},
+			want:        controllerutil.OperationResultUpdated,
+			wantApplies: 1,
+		},
+		{
+			name: "replaces rolebinding bound to another role",
+			objs: []client.Object{&rbacv1.RoleBinding{
+				ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team"},
+				Subjects:   spec.Subjects,
+			}},
+			want:        controllerutil.OperationResultUpdated,
+			wantApplies: 1,
+		},
+		{
+			name:        "takes over fields of other field managers on conflict",
+			objs:        []client.Object{existing(rbacv1.Subject{Kind: rbacv1.UserKind, Name: "john", APIGroup: rbacv1.GroupName})},
+			conflict:    true,
+			want:        controllerutil.OperationResultUpdated,
+			wantApplies: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applies := 0
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).
+				WithInterceptorFuncs(fakeApply(&applies, tt.conflict)).Build()
+			_, got, err := applyRoleBinding(context.Background(), c, scheme.Scheme, changeRecorder{}, spec)
+			if err != nil {
+				t.Fatalf("applyRoleBinding() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("applyRoleBinding() = %v, want %v", got, tt.want)
+			}
+			if applies != tt.wantApplies {
+				t.Errorf("applyRoleBinding() applied %d times, want %d", applies, tt.wantApplies)
+			}
+			rb := &rbacv1.RoleBinding{}
+			if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team", Name: "team"}, rb); err != nil {
+				t.Fatalf("unable to get rolebinding: %v", err)
+			}
+			if rb.RoleRef != spec.RoleRef {
+				t.Errorf("applyRoleBinding() roleRef = %v, want %v", rb.RoleRef, spec.RoleRef)
+			}
+			if !subjectsEqual(rb.Subjects, spec.Subjects) {
+				t.Errorf("applyRoleBinding() subjects mismatch (-want +got):\n%s", cmp.Diff(spec.Subjects, rb.Subjects))
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+)
+
+// PermissionAnnotationHash returns a hash over all permission annotations of the namespace
+func PermissionAnnotationHash(ns *corev1.Namespace) string {
+	h := sha256.New()
+	for _, annotation := range PermissionAnnotations {
+		if value, ok := ns.Annotations[annotation]; ok {
+			h.Write([]byte(annotation + "=" + value + "\n"))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// namespaceOrigin describes a permission change triggered by the annotations of the namespace
+func namespaceOrigin(ns *corev1.Namespace) audit.Origin {
+	// a broken managed fields entry must not prevent the change from being recorded
+	manager, _ := LastPermissionFieldManager(ns)
+	return audit.Origin{
+		Kind:           "Namespace",
+		Name:           ns.Name,
+		AnnotationHash: PermissionAnnotationHash(ns),
+		FieldManager:   manager,
+	}
+}
+
+// auditOperation maps the result of applying or deleting an object to an audit operation.
+// Unchanged objects aren't recorded.
+func auditOperation(result controllerutil.OperationResult) (audit.Operation, bool) {
+	switch result {
+	case controllerutil.OperationResultCreated:
+		return audit.OperationCreated, true
+	case controllerutil.OperationResultUpdated:
+		return audit.OperationUpdated, true
+	case OperationDeleted:
+		return audit.OperationDeleted, true
+	}
+	return "", false
+}
+
+// roleBindingState returns the audited state of a RoleBinding
+func roleBindingState(rb *rbacv1.RoleBinding) *audit.State {
+	if rb == nil {
+		return nil
+	}
+	roleRef := rb.RoleRef
+	return &audit.State{Subjects: rb.Subjects, RoleRef: &roleRef}
+}
+
+// roleState returns the audited state of a Role
+func roleState(role *rbacv1.Role) *audit.State {
+	if role == nil {
+		return nil
+	}
+	return &audit.State{Rules: role.Rules}
+}
+
+// clusterRoleState returns the audited state of a ClusterRole
+func clusterRoleState(cr *rbacv1.ClusterRole) *audit.State {
+	if cr == nil {
+		return nil
+	}
+	return &audit.State{Rules: cr.Rules}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/health"
+)
+
+const (
+	// LabelBreakGlass is set on RoleBindings created for a BreakGlass
+	LabelBreakGlass = "ns.tagesspiegel.de/break-glass"
+
+	EventReasonBreakGlassActivated = "BreakGlassActivated"
+	EventReasonBreakGlassRevoked   = "BreakGlassRevoked"
+	EventReasonBreakGlassRejected  = "BreakGlassRejected"
+)
+
+// BreakGlassReconciler grants a configured ClusterRole in a managed namespace
+// for the duration of a BreakGlass and revokes it afterwards
+type BreakGlassReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Guard protects namespaces and subjects from ever being managed.
+	// A nil Guard protects nothing.
+	Guard *Guard
+	// ClusterRole is bound to the subject of every BreakGlass
+	ClusterRole string
+	// MaxDuration caps the duration of every BreakGlass. Zero means no limit.
+	MaxDuration time.Duration
+	// Audit records every change of a RoleBinding. A nil Audit records nothing.
+	Audit *audit.Logger
+	// Stalls tracks running reconciliations for the liveness check. A nil Stalls tracks nothing.
+	Stalls *health.StallDetector
+	// DryRun only plans changes. The Client is expected to be a dry-run client, so no change is persisted.
+	DryRun bool
+}
+
+//+kubebuilder:rbac:groups=ns.tagesspiegel.de,resources=breakglasses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ns.tagesspiegel.de,resources=breakglasses/status,verbs=get;update;patch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *BreakGlassReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Stalls.Begin()()
+	logx := log.FromContext(ctx)
+
+	bg := &nsv1alpha1.BreakGlass{}
+	if err := r.Client.Get(ctx, req.NamespacedName, bg); err != nil {
+		if errors.IsNotFound(err) {
+			// the RoleBinding is garbage collected through its owner reference
+			logx.V(100).Info("break glass not found, ignoring")
+			return ctrl.Result{}, nil
+		}
+		logx.Error(err, "unable to fetch BreakGlass")
+		return ctrl.Result{}, err
+	}
+
+	rbName := "break-glass-" + bg.Name
+	origin := audit.Origin{Kind: "BreakGlass", Name: bg.Name}
+	status := bg.Status.DeepCopy()
+	status.RoleBindingName = rbName
+
+	var result ctrl.Result
+	now := time.Now()
+	if status.GrantedAt != nil {
+		// a grant time in the future would postpone the expiry
+		if status.GrantedAt.After(now) {
+			status.GrantedAt = &metav1.Time{Time: now}
+		}
+		// the expiry is recomputed every time, so extending it in the status doesn't extend the access
+		status.ExpiresAt = &metav1.Time{Time: status.GrantedAt.Add(r.duration(bg))}
+	}
+	switch {
+	case status.Phase == nsv1alpha1.BreakGlassRejected:
+		// a rejected BreakGlass can't become active later on, as its spec is immutable
+		return ctrl.Result{}, nil
+	case status.ExpiresAt != nil && !now.Before(status.ExpiresAt.Time):
+		if status.Phase == nsv1alpha1.BreakGlassActive {
+			r.event(ctx, bg, EventReasonBreakGlassRevoked, "emergency access of %s %q to namespace %q revoked",
+				bg.Spec.Subject.Kind, bg.Spec.Subject.Name, bg.Spec.Namespace)
+		}
+		status.Phase = nsv1alpha1.BreakGlassExpired
+		if err := deleteRoleBinding(ctx, r.Client, r.changes(), origin, bg.Spec.Namespace, rbName); err != nil {
+			logx.Error(err, "unable to delete rolebinding")
+			return ctrl.Result{}, err
+		}
+	default:
+		msg, err := r.reject(ctx, bg)
+		if err != nil {
+			logx.Error(err, "unable to fetch Namespace")
+			return ctrl.Result{}, err
+		}
+		if msg != "" {
+			logx.Info("break glass rejected", "reason", msg)
+			r.Recorder.Event(bg, corev1.EventTypeWarning, EventReasonBreakGlassRejected, msg)
+			status.Phase = nsv1alpha1.BreakGlassRejected
+			status.Message = msg
+			break
+		}
+		if status.GrantedAt == nil {
+			status.GrantedAt = &metav1.Time{Time: now}
+			status.ExpiresAt = &metav1.Time{Time: now.Add(r.duration(bg))}
+		}
+		_, rslt, err := applyRoleBinding(ctx, r.Client, r.Scheme, r.changes(), RoleBindingSpec{
+			Name:      rbName,
+			Namespace: bg.Spec.Namespace,
+			RoleRef:   rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: r.ClusterRole},
+			Subjects:  []rbacv1.Subject{bg.Spec.Subject},
+			Labels:    map[string]string{LabelBreakGlass: bg.Name},
+			Owner:     bg,
+			Origin:    origin,
+		})
+		if err != nil {
+			logx.Error(err, "unable to apply rolebinding")
+			return ctrl.Result{}, err
+		}
+		logx.V(80).Info("result for reconciliation for role binding", "result", rslt)
+		if status.Phase != nsv1alpha1.BreakGlassActive {
+			logx.Info("break glass activated", "namespace", bg.Spec.Namespace, "subject", bg.Spec.Subject,
+				"clusterRole", r.ClusterRole, "reason", bg.Spec.Reason, "expiresAt", status.ExpiresAt)
+			breakGlassActivations.WithLabelValues(bg.Spec.Namespace).Inc()
+			r.event(ctx, bg, EventReasonBreakGlassActivated, "emergency access granted to %s %q in namespace %q until %s: %s",
+				bg.Spec.Subject.Kind, bg.Spec.Subject.Name, bg.Spec.Namespace, status.ExpiresAt.Format(time.RFC3339), bg.Spec.Reason)
+		}
+		status.Phase = nsv1alpha1.BreakGlassActive
+		// come back once the access expires
+		result.RequeueAfter = time.Until(status.ExpiresAt.Time)
+	}
+
+	if equality.Semantic.DeepEqual(bg.Status, *status) {
+		return result, nil
+	}
+	bg.Status = *status
+	if err := r.Status().Update(ctx, bg); err != nil {
+		logx.Error(err, "unable to update break glass status")
+		return ctrl.Result{}, err
+	}
+	if err := r.updateActiveGauge(ctx); err != nil {
+		logx.Error(err, "unable to count active break glasses")
+	}
+	return result, nil
+}
+
+// reject returns why the BreakGlass can't be granted or an empty string if it can
+func (r *BreakGlassReconciler) reject(ctx context.Context, bg *nsv1alpha1.BreakGlass) (string, error) {
+	if bg.Spec.Duration.Duration <= 0 {
+		return "duration has to be positive", nil
+	}
+	if r.Guard.IsProtectedNamespace(bg.Spec.Namespace) {
+		return fmt.Sprintf("%s: %q", ErrProtectedNamespace, bg.Spec.Namespace), nil
+	}
+	if err := r.Guard.CheckSubjects([]rbacv1.Subject{bg.Spec.Subject}); err != nil {
+		return err.Error(), nil
+	}
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: bg.Spec.Namespace}, ns); err != nil {
+		// the cache only holds managed namespaces
+		if errors.IsNotFound(err) {
+			return fmt.Sprintf("namespace %q not found or not managed", bg.Spec.Namespace), nil
+		}
+		return "", err
+	}
+	if _, ok := ns.Labels[LabelNamespacePermissionControl]; !ok {
+		return fmt.Sprintf("namespace %q is not managed", bg.Spec.Namespace), nil
+	}
+	return "", nil
+}
+
+// duration returns the duration of the BreakGlass, capped to the maximum duration
+func (r *BreakGlassReconciler) duration(bg *nsv1alpha1.BreakGlass) time.Duration {
+	if r.MaxDuration > 0 && bg.Spec.Duration.Duration > r.MaxDuration {
+		return r.MaxDuration
+	}
+	return bg.Spec.Duration.Duration
+}
+
+// event records a warning on the BreakGlass and the affected namespace so the access is visible in both places
+func (r *BreakGlassReconciler) event(ctx context.Context, bg *nsv1alpha1.BreakGlass, reason, messageFmt string, args ...interface{}) {
+	r.Recorder.Eventf(bg, corev1.EventTypeWarning, reason, messageFmt, args...)
+	ns := &corev1.Namespace{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: bg.Spec.Namespace}, ns); err == nil {
+		r.Recorder.Eventf(ns, corev1.EventTypeWarning, reason, messageFmt, args...)
+	}
+}
+
+func (r *BreakGlassReconciler) updateActiveGauge(ctx context.Context) error {
+	list := &nsv1alpha1.BreakGlassList{}
+	if err := r.Client.List(ctx, list); err != nil {
+		return err
+	}
+	active := 0
+	for _, bg := range list.Items {
+		if bg.Status.Phase == nsv1alpha1.BreakGlassActive {
+			active++
+		}
+	}
+	breakGlassActive.Set(float64(active))
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BreakGlassReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&nsv1alpha1.BreakGlass{}).
+		Owns(&rbacv1.RoleBinding{}).
+		WithOptions(stallOptions(r.Stalls)).
+		Complete(r)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+)
+
+func TestBreakGlassReconciler_Reconcile(t *testing.T) {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = nsv1alpha1.AddToScheme(scheme)
+
+	managed := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test",
+		Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+	}}
+	unmanaged := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}}
+	breakGlass := func(namespace string, status nsv1alpha1.BreakGlassStatus) *nsv1alpha1.BreakGlass {
+		return &nsv1alpha1.BreakGlass{
+			ObjectMeta: metav1.ObjectMeta{Name: "incident"},
+			Spec: nsv1alpha1.BreakGlassSpec{
+				Namespace: namespace,
+				Subject:   rbacv1.Subject{Kind: rbacv1.UserKind, Name: "oncall", APIGroup: rbacv1.GroupName},
+				Duration:  metav1.Duration{Duration: 2 * time.Hour},
+				Reason:    "incident",
+			},
+			Status: status,
+		}
+	}
+	past := metav1.NewTime(time.Now().Add(-time.Minute))
+	granted := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	extended := metav1.NewTime(time.Now().Add(24 * time.Hour))
+
+	tests := []struct {
+		name        string
+		obj         *nsv1alpha1.BreakGlass
+		wantPhase   nsv1alpha1.BreakGlassPhase
+		wantBinding bool
+	}{
+		{
+			name:        "grants access in managed namespace",
+			obj:         breakGlass("test", nsv1alpha1.BreakGlassStatus{}),
+			wantPhase:   nsv1alpha1.BreakGlassActive,
+			wantBinding: true,
+		},
+		{
+			name:      "rejects unmanaged namespace",
+			obj:       breakGlass("unmanaged", nsv1alpha1.BreakGlassStatus{}),
+			wantPhase: nsv1alpha1.BreakGlassRejected,
+		},
+		{
+			name:      "rejects protected namespace",
+			obj:       breakGlass("kube-system", nsv1alpha1.BreakGlassStatus{}),
+			wantPhase: nsv1alpha1.BreakGlassRejected,
+		},
+		{
+			name: "rejects zero duration",
+			obj: func() *nsv1alpha1.BreakGlass {
+				bg := breakGlass("test", nsv1alpha1.BreakGlassStatus{})
+				bg.Spec.Duration.Duration = 0
+				return bg
+			}(),
+			wantPhase: nsv1alpha1.BreakGlassRejected,
+		},
+		{
+			name:      "revokes expired access",
+			obj:       breakGlass("test", nsv1alpha1.BreakGlassStatus{Phase: nsv1alpha1.BreakGlassActive, GrantedAt: &granted, ExpiresAt: &past}),
+			wantPhase: nsv1alpha1.BreakGlassExpired,
+		},
+		{
+			name:      "ignores an extended expiry",
+			obj:       breakGlass("test", nsv1alpha1.BreakGlassStatus{Phase: nsv1alpha1.BreakGlassActive, GrantedAt: &granted, ExpiresAt: &extended}),
+			wantPhase: nsv1alpha1.BreakGlassExpired,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(managed, unmanaged, tt.obj).
+				WithStatusSubresource(&nsv1alpha1.BreakGlass{}).
+				WithInterceptorFuncs(fakeApply(nil, false)).
+				Build()
+			r := &BreakGlassReconciler{
+				Client:      c,
+				Scheme:      scheme,
+				Recorder:    record.NewFakeRecorder(10),
+				Guard:       &Guard{ProtectedNamespaces: []string{"kube-*"}},
+				ClusterRole: "admin",
+				MaxDuration: time.Hour,
+			}
+			ctx := context.Background()
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(tt.obj)}); err != nil {
+				t.Fatalf("BreakGlassReconciler.Reconcile() error = %v", err)
+			}
+			bg := &nsv1alpha1.BreakGlass{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(tt.obj), bg); err != nil {
+				t.Fatal(err)
+			}
+			if bg.Status.Phase != tt.wantPhase {
+				t.Errorf("BreakGlassReconciler.Reconcile() phase = %v, want %v", bg.Status.Phase, tt.wantPhase)
+			}
+			rb := &rbacv1.RoleBinding{}
+			err := c.Get(ctx, client.ObjectKey{Namespace: tt.obj.Spec.Namespace, Name: "break-glass-incident"}, rb)
+			if tt.wantBinding != !errors.IsNotFound(err) {
+				t.Errorf("BreakGlassReconciler.Reconcile() rolebinding exists = %v, want %v", err == nil, tt.wantBinding)
+			}
+			if tt.wantBinding && bg.Status.ExpiresAt.Sub(bg.Status.GrantedAt.Time) != time.Hour {
+				t.Errorf("BreakGlassReconciler.Reconcile() duration = %v, want capped to %v",
+					bg.Status.ExpiresAt.Sub(bg.Status.GrantedAt.Time), time.Hour)
+			}
+		})
+	}
+}
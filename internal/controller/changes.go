@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+)
+
+// changeRecorder records the changes of managed Roles and RoleBindings in the metrics and the audit log.
+// In dry-run mode the changes haven't been persisted, so they are logged and counted as planned instead.
+type changeRecorder struct {
+	audit  *audit.Logger
+	dryRun bool
+}
+
+func (r *NamespaceReconciler) changes() changeRecorder {
+	return changeRecorder{audit: r.Audit, dryRun: r.DryRun}
+}
+
+func (r *AccessRequestReconciler) changes() changeRecorder {
+	return changeRecorder{audit: r.Audit, dryRun: r.DryRun}
+}
+
+func (r *BreakGlassReconciler) changes() changeRecorder {
+	return changeRecorder{audit: r.Audit, dryRun: r.DryRun}
+}
+
+// record records the result of creating, updating or deleting a Role or RoleBinding
+func (c changeRecorder) record(ctx context.Context, result controllerutil.OperationResult, kind, namespace, name string, origin audit.Origin, before, after *audit.State) {
+	if !c.dryRun {
+		recordOperation(kind, result)
+		writeAudit(ctx, c.audit, result, kind, namespace, name, origin, before, after, false)
+		return
+	}
+	recordPlannedOperation(kind, result)
+	if _, ok := auditOperation(result); ok {
+		log.FromContext(ctx).Info("planned change", "operation", result, "kind", kind, "namespace", namespace, "name", name,
+			"origin", origin.Kind+"/"+origin.Name, "diff", audit.Compare(before, after))
+	}
+	writeAudit(ctx, c.audit, result, kind, namespace, name, origin, before, after, true)
+}
+
+// writeAudit records a change of a Role or RoleBinding. Failures are logged, as the change has already been applied.
+func writeAudit(ctx context.Context, auditLog *audit.Logger, result controllerutil.OperationResult, kind, namespace, name string, origin audit.Origin, before, after *audit.State, dryRun bool) {
+	op, ok := auditOperation(result)
+	if !ok {
+		return
+	}
+	err := auditLog.Log(audit.Record{
+		Operation: op,
+		Kind:      kind,
+		Namespace: namespace,
+		Name:      name,
+		Origin:    origin,
+		Before:    before,
+		After:     after,
+		DryRun:    dryRun,
+	})
+	if err != nil {
+		log.FromContext(ctx).Error(err, "unable to write audit record", "kind", kind, "namespace", namespace, "name", name)
+	}
+}
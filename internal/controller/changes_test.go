@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+)
+
+func TestNamespaceReconciler_DryRun(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				AnnotationNamespaceCustomRoleRules:     "apiGroups=;resources=pods;verbs=get",
+			},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	live := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "john", APIGroup: rbacv1.GroupName}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, live).
+		WithInterceptorFuncs(fakeApply(nil, false)).Build()
+
+	var buf bytes.Buffer
+	r := &NamespaceReconciler{
+		Client:   client.NewDryRunClient(c),
+		Scheme:   scheme.Scheme,
+		Recorder: record.NewFakeRecorder(10),
+		Audit:    audit.New(&buf),
+		DryRun:   true,
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// nothing has been persisted
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team", Name: "team"}, &rbacv1.Role{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the Role not to be created, got %v", err)
+	}
+	rb := &rbacv1.RoleBinding{}
+	if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team", Name: "team"}, rb); err != nil {
+		t.Fatalf("unable to get rolebinding: %v", err)
+	}
+	if diff := cmp.Diff(live.Subjects, rb.Subjects); diff != "" {
+		t.Errorf("RoleBinding subjects changed (-want +got):\n%s", diff)
+	}
+
+	// the planned changes are audited
+	type planned struct {
+		Operation audit.Operation
+		Kind      string
+		DryRun    bool
+	}
+	var got []planned
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec audit.Record
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid audit record %q: %v", line, err)
+		}
+		got = append(got, planned{Operation: rec.Operation, Kind: rec.Kind, DryRun: rec.DryRun})
+	}
+	want := []planned{
+		{Operation: audit.OperationCreated, Kind: "Role", DryRun: true},
+		{Operation: audit.OperationUpdated, Kind: "RoleBinding", DryRun: true},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("audit records mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNamespaceReconciler_DryRunRoleRefChange(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=view",
+			},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	live := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team-a", APIGroup: rbacv1.GroupName}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, live).
+		WithInterceptorFuncs(fakeApply(nil, false)).Build()
+
+	var buf bytes.Buffer
+	r := &NamespaceReconciler{
+		Client:   client.NewDryRunClient(c),
+		Scheme:   scheme.Scheme,
+		Recorder: record.NewFakeRecorder(10),
+		Audit:    audit.New(&buf),
+		DryRun:   true,
+	}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	var rec audit.Record
+	if err := json.Unmarshal(bytes.TrimSpace(buf.Bytes()), &rec); err != nil {
+		t.Fatalf("invalid audit record %q: %v", buf.String(), err)
+	}
+	// only the roleRef changes, the subjects are the same once defaulted
+	want := audit.Diff{RoleRefChanged: true}
+	if diff := cmp.Diff(want, rec.Diff); diff != "" {
+		t.Errorf("audit diff mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// AnnotationManaged marks a namespace the controller reconciled while it carried the permission-control label.
+// Only marked namespaces are cleaned up once they lose the label, so objects labelled as managed before the
+// namespace is labelled, e.g. by nspm import --relabel, are kept.
+const AnnotationManaged = "ns.tagesspiegel.de/permissions-managed"
+
+// markManaged sets AnnotationManaged on the namespace
+func (r *NamespaceReconciler) markManaged(ctx context.Context, ns *corev1.Namespace) error {
+	if ns.Annotations[AnnotationManaged] == "true" {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	if ns.Annotations == nil {
+		ns.Annotations = map[string]string{}
+	}
+	ns.Annotations[AnnotationManaged] = "true"
+	return r.Patch(ctx, ns, patch)
+}
+
+// unmarkManaged removes AnnotationManaged from the namespace once it was cleaned up
+func (r *NamespaceReconciler) unmarkManaged(ctx context.Context, ns *corev1.Namespace) error {
+	if _, ok := ns.Annotations[AnnotationManaged]; !ok {
+		return nil
+	}
+	patch := client.MergeFrom(ns.DeepCopy())
+	delete(ns.Annotations, AnnotationManaged)
+	return r.Patch(ctx, ns, patch)
+}
+
+// cleanup deletes the Role and RoleBinding created for a namespace that lost the permission-control label.
+// Objects without the managed labels, e.g. created by hand, are kept. It reports whether anything was deleted.
+func (r *NamespaceReconciler) cleanup(ctx context.Context, ns *corev1.Namespace) (bool, error) {
+	key := client.ObjectKey{Namespace: ns.Name, Name: ns.Name}
+	origin := namespaceOrigin(ns)
+	cleaned := false
+
+	rb := &rbacv1.RoleBinding{}
+	exists, err := getExisting(ctx, r.Client, key, rb)
+	if err != nil {
+		return cleaned, err
+	}
+	if exists && managedFor(rb, ns.Name) {
+		if err := deleteRoleBinding(ctx, r.Client, r.changes(), origin, ns.Name, ns.Name); err != nil {
+			return cleaned, err
+		}
+		cleaned = true
+	}
+
+	removed, err := r.removeRole(ctx, ns)
+	return cleaned || removed, err
+}
+
+// removeRole deletes the managed Role of the namespace and reports whether it existed
+func (r *NamespaceReconciler) removeRole(ctx context.Context, ns *corev1.Namespace) (bool, error) {
+	role := &rbacv1.Role{}
+	exists, err := getExisting(ctx, r.Client, client.ObjectKey{Namespace: ns.Name, Name: ns.Name}, role)
+	if err != nil || !exists || !managedFor(role, ns.Name) {
+		return false, err
+	}
+	if err := deleteRole(ctx, r.Client, r.changes(), namespaceOrigin(ns), ns.Name, ns.Name); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// managedFor reports whether the controller created the object for the given namespace
+func managedFor(obj client.Object, namespace string) bool {
+	return hasLabels(obj, managedLabels(namespace))
+}
+
+// sourceNamespace maps a managed object to the namespace it was created for.
+// RoleBindings of AccessRequests and BreakGlasses are reconciled by their own controllers and skipped.
+func sourceNamespace(_ context.Context, obj client.Object) []reconcile.Request {
+	namespace := obj.GetLabels()[LabelNamespaceName]
+	if namespace == "" || obj.GetLabels()[LabelManagedBy] != ManagedByValue {
+		return nil
+	}
+	if _, ok := obj.GetLabels()[LabelAccessRequest]; ok {
+		return nil
+	}
+	if _, ok := obj.GetLabels()[LabelBreakGlass]; ok {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: namespace}}}
+}
@@ -0,0 +1,232 @@
+package controller
+
+import (
+	"context"
+	"maps"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// labelledNamespaces emulates the cache of the manager, which only holds namespaces carrying the permission-control label
+func labelledNamespaces() interceptor.Funcs {
+	return interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if err := c.Get(ctx, key, obj, opts...); err != nil {
+				return err
+			}
+			if _, ok := obj.(*corev1.Namespace); ok {
+				if _, labelled := obj.GetLabels()[LabelNamespacePermissionControl]; !labelled {
+					return apierrors.NewNotFound(corev1.Resource("namespaces"), key.Name)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestNamespaceReconciler_Cleanup(t *testing.T) {
+	namespace := func(annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Annotations: annotations},
+			Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+		}
+	}
+	managed := map[string]string{AnnotationManaged: "true"}
+	role := &rbacv1.Role{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+	}
+	binding := func(labels map[string]string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: labels},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team"},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		objs        []client.Object
+		wantRole    bool
+		wantBinding bool
+	}{
+		{
+			name: "removes managed objects of namespace that lost the label",
+			objs: []client.Object{namespace(managed), role, binding(managedLabels("team"))},
+		},
+		{
+			// nspm import --relabel labels the objects before the namespace
+			name:        "keeps relabelled objects of namespace that was never managed",
+			objs:        []client.Object{namespace(nil), role, binding(managedLabels("team"))},
+			wantRole:    true,
+			wantBinding: true,
+		},
+		{
+			name:        "keeps objects created by hand",
+			objs:        []client.Object{namespace(managed), binding(map[string]string{"team": "ops"})},
+			wantBinding: true,
+		},
+		{
+			name: "keeps objects of paused namespace",
+			objs: []client.Object{
+				namespace(map[string]string{AnnotationManaged: "true", AnnotationReconcilePaused: "true"}),
+				role, binding(managedLabels("team")),
+			},
+			wantRole:    true,
+			wantBinding: true,
+		},
+		{
+			name: "ignores deleted namespace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiReader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build()
+			r := &NamespaceReconciler{
+				Client:    interceptor.NewClient(apiReader, labelledNamespaces()),
+				APIReader: apiReader,
+				Scheme:    scheme.Scheme,
+				Recorder:  record.NewFakeRecorder(10),
+			}
+			ctx := context.Background()
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			key := client.ObjectKey{Namespace: "team", Name: "team"}
+			if err := apiReader.Get(ctx, key, &rbacv1.Role{}); tt.wantRole == apierrors.IsNotFound(err) {
+				t.Errorf("role exists = %v, want %v", err == nil, tt.wantRole)
+			}
+			if err := apiReader.Get(ctx, key, &rbacv1.RoleBinding{}); tt.wantBinding == apierrors.IsNotFound(err) {
+				t.Errorf("rolebinding exists = %v, want %v", err == nil, tt.wantBinding)
+			}
+		})
+	}
+}
+
+func TestNamespaceReconciler_CleanupAfterImport(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "team"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	// relabelled by nspm import --relabel before the namespace is labelled
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "team", APIGroup: rbacv1.GroupName}},
+	}
+	apiReader := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns, binding).Build()
+	r := &NamespaceReconciler{
+		Client:    interceptor.NewClient(apiReader, labelledNamespaces()),
+		APIReader: apiReader,
+		Scheme:    scheme.Scheme,
+		Recorder:  record.NewFakeRecorder(10),
+	}
+	ctx := context.Background()
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ns)}); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	updateNamespace := func(mutate func(*corev1.Namespace)) {
+		t.Helper()
+		current := &corev1.Namespace{}
+		if err := apiReader.Get(ctx, client.ObjectKeyFromObject(ns), current); err != nil {
+			t.Fatal(err)
+		}
+		mutate(current)
+		if err := apiReader.Update(ctx, current); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bindingExists := func() bool {
+		err := apiReader.Get(ctx, client.ObjectKeyFromObject(binding), &rbacv1.RoleBinding{})
+		return !apierrors.IsNotFound(err)
+	}
+
+	// the relabelled binding triggers a reconcile of the still unlabelled namespace
+	reconcile()
+	if !bindingExists() {
+		t.Fatal("rolebinding relabelled before the namespace was labelled was deleted")
+	}
+
+	// the namespace patch printed by nspm import is applied
+	updateNamespace(func(ns *corev1.Namespace) {
+		ns.Labels = map[string]string{LabelNamespacePermissionControl: "manage"}
+		ns.Annotations = map[string]string{
+			AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team;apiGroup=rbac.authorization.k8s.io",
+		}
+	})
+	reconcile()
+	if !bindingExists() {
+		t.Fatal("rolebinding of labelled namespace was deleted")
+	}
+
+	// the namespace loses the label again, now the binding is cleaned up
+	updateNamespace(func(ns *corev1.Namespace) {
+		if ns.Annotations[AnnotationManaged] != "true" {
+			t.Errorf("annotation %s = %q, want true", AnnotationManaged, ns.Annotations[AnnotationManaged])
+		}
+		delete(ns.Labels, LabelNamespacePermissionControl)
+	})
+	reconcile()
+	if bindingExists() {
+		t.Error("rolebinding of namespace that lost the label was kept")
+	}
+	current := &corev1.Namespace{}
+	if err := apiReader.Get(ctx, client.ObjectKeyFromObject(ns), current); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := current.Annotations[AnnotationManaged]; ok {
+		t.Errorf("annotation %s kept after cleanup", AnnotationManaged)
+	}
+}
+
+func Test_sourceNamespace(t *testing.T) {
+	binding := func(labels map[string]string) *rbacv1.RoleBinding {
+		l := managedLabels("team")
+		maps.Copy(l, labels)
+		return &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "team", Labels: l}}
+	}
+	tests := []struct {
+		name string
+		obj  client.Object
+		want []reconcile.Request
+	}{
+		{
+			name: "managed rolebinding",
+			obj:  binding(nil),
+			want: []reconcile.Request{{NamespacedName: client.ObjectKey{Name: "team"}}},
+		},
+		{
+			name: "unmanaged rolebinding",
+			obj:  &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "binding", Namespace: "team"}},
+		},
+		{
+			name: "rolebinding of an access request",
+			obj:  binding(map[string]string{LabelAccessRequest: "debug"}),
+		},
+		{
+			name: "rolebinding of a break glass",
+			obj:  binding(map[string]string{LabelBreakGlass: "incident"}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if diff := cmp.Diff(tt.want, sourceNamespace(context.Background(), tt.obj)); diff != "" {
+				t.Errorf("sourceNamespace() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
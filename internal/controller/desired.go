@@ -0,0 +1,150 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/tracing"
+)
+
+// AnnotationError is returned for a permission annotation that can't be parsed
+type AnnotationError struct {
+	Annotation string
+	Err        error
+}
+
+func (e *AnnotationError) Error() string {
+	return fmt.Sprintf("invalid annotation %q: %s", e.Annotation, e.Err)
+}
+
+func (e *AnnotationError) Unwrap() error {
+	return e.Err
+}
+
+// AnnotationErrors returns all AnnotationErrors joined into err
+func AnnotationErrors(err error) []*AnnotationError {
+	var out []*AnnotationError
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			out = append(out, AnnotationErrors(e)...)
+		}
+		return out
+	}
+	var ae *AnnotationError
+	if errors.As(err, &ae) {
+		out = append(out, ae)
+	}
+	return out
+}
+
+// DesiredState is the Role and RoleBinding the controller maintains for a namespace
+type DesiredState struct {
+	// Role is the custom Role of the namespace. It is nil if the namespace has no custom rules.
+	Role *rbacv1.Role
+	// ClusterRole holds the custom rules instead of the Role if they are shared with other namespaces, see ShareRole
+	ClusterRole *rbacv1.ClusterRole
+	// RoleBinding binds all subjects whose access hasn't expired. It is nil if the namespace has no subjects.
+	RoleBinding *rbacv1.RoleBinding
+
+	// RoleRef is the role the subjects are bound to. Custom rules take precedence over the roleRef annotation.
+	RoleRef rbacv1.RoleRef
+	// Rules are the parsed custom rules
+	Rules []rbacv1.PolicyRule
+	// Subjects are all parsed subjects, including the expired ones
+	Subjects []TimedSubject
+	// Expired are the subjects whose access has expired
+	Expired []rbacv1.Subject
+	// NextExpiry is the next point in time an active subject expires, or the zero time if none expires
+	NextExpiry time.Time
+}
+
+// Desired computes the Role and RoleBinding the controller applies for the permission annotations of the namespace
+// at the given point in time. It neither reads from nor writes to the cluster, so the command line tools render
+// exactly what the controller applies.
+//
+// All annotations are parsed, even if one of them is invalid. The returned error joins an AnnotationError for
+// every invalid annotation; in that case the state holds the successfully parsed values but no objects.
+func Desired(ns *corev1.Namespace, now time.Time) (*DesiredState, error) {
+	return DesiredContext(context.Background(), ns, now)
+}
+
+// DesiredContext is Desired, tracing the parsing of every annotation as a child span of the span in ctx
+func DesiredContext(ctx context.Context, ns *corev1.Namespace, now time.Time) (*DesiredState, error) {
+	state := &DesiredState{}
+	var errs []error
+
+	if rf, ok := ns.Annotations[AnnotationNamespaceRoleBindingRoleRef]; ok {
+		_, span := tracing.Tracer().Start(ctx, "ParseRoleBindingRoleRef")
+		roleRef, err := ParseRoleBindingRoleRef(rf)
+		tracing.End(span, err)
+		if err != nil {
+			errs = append(errs, &AnnotationError{Annotation: AnnotationNamespaceRoleBindingRoleRef, Err: err})
+		} else {
+			state.RoleRef = roleRef
+		}
+	}
+
+	cr, hasCustomRules := ns.Annotations[AnnotationNamespaceCustomRoleRules]
+	if hasCustomRules {
+		_, span := tracing.Tracer().Start(ctx, "ParseCustomRole")
+		rules, err := ParseCustomRole(cr)
+		tracing.End(span, err)
+		if err != nil {
+			errs = append(errs, &AnnotationError{Annotation: AnnotationNamespaceCustomRoleRules, Err: err})
+		} else {
+			state.Rules = rules
+			// the custom role has priority over the role ref
+			state.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: ns.Name}
+		}
+	}
+
+	rbSubjects, hasSubjects := ns.Annotations[AnnotationNamespaceRoleBindingSubjects]
+	if hasSubjects {
+		_, span := tracing.Tracer().Start(ctx, "ParseTimedRoleBindingSubjects")
+		timed, err := ParseTimedRoleBindingSubjects(rbSubjects)
+		tracing.End(span, err)
+		if err != nil {
+			errs = append(errs, &AnnotationError{Annotation: AnnotationNamespaceRoleBindingSubjects, Err: err})
+		} else {
+			state.Subjects = timed
+		}
+	}
+
+	if len(errs) > 0 {
+		return state, errors.Join(errs...)
+	}
+
+	if hasCustomRules {
+		state.Role = &rbacv1.Role{
+			TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ns.Name,
+				Namespace: ns.Name,
+				Labels:    managedLabels(ns.Name),
+			},
+			Rules: state.Rules,
+		}
+	}
+	if hasSubjects {
+		var active []rbacv1.Subject
+		// drop all subjects whose access has expired
+		active, state.Expired, state.NextExpiry = ActiveSubjects(state.Subjects, now)
+		state.RoleBinding = &rbacv1.RoleBinding{
+			TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ns.Name,
+				Namespace: ns.Name,
+				Labels:    managedLabels(ns.Name),
+			},
+			RoleRef:  state.RoleRef,
+			Subjects: active,
+		}
+	}
+	return state, nil
+}
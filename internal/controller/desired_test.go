@@ -0,0 +1,149 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDesired(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	nextExpiry := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	meta := func(kind string) (metav1.TypeMeta, metav1.ObjectMeta) {
+		return metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: kind},
+			metav1.ObjectMeta{Name: "team-a", Namespace: "team-a", Labels: managedLabels("team-a")}
+	}
+	roleBinding := func(roleRef rbacv1.RoleRef, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		typeMeta, objectMeta := meta("RoleBinding")
+		return &rbacv1.RoleBinding{TypeMeta: typeMeta, ObjectMeta: objectMeta, RoleRef: roleRef, Subjects: subjects}
+	}
+	edit := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"}
+	custom := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}
+	rules := []rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	group := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team-a"}
+	contractor := rbacv1.Subject{Kind: rbacv1.UserKind, Name: "contractor"}
+
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		wantRole       bool
+		wantRoleRef    rbacv1.RoleRef
+		wantBinding    *rbacv1.RoleBinding
+		wantExpired    []rbacv1.Subject
+		wantNextExpiry time.Time
+		wantErrors     []string
+	}{
+		{
+			name: "role ref",
+			annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			},
+			wantRoleRef: edit,
+			wantBinding: roleBinding(edit, group),
+		},
+		{
+			name: "custom rules take precedence over the role ref",
+			annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				AnnotationNamespaceCustomRoleRules:     "verbs=get;apiGroups=;resources=pods",
+			},
+			wantRole:    true,
+			wantRoleRef: custom,
+			wantBinding: roleBinding(custom, group),
+		},
+		{
+			name: "custom rules without subjects",
+			annotations: map[string]string{
+				AnnotationNamespaceCustomRoleRules: "verbs=get;apiGroups=;resources=pods",
+			},
+			wantRole:    true,
+			wantRoleRef: custom,
+		},
+		{
+			name: "expired subjects are not bound",
+			annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a;expires=2026-11-01T00:00:00Z,kind=User;name=contractor;expires=2026-09-01T00:00:00Z",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			},
+			wantRoleRef:    edit,
+			wantBinding:    roleBinding(edit, group),
+			wantExpired:    []rbacv1.Subject{contractor},
+			wantNextExpiry: nextExpiry,
+		},
+		{
+			name: "all invalid annotations are reported",
+			annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;foo=bar",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				AnnotationNamespaceCustomRoleRules:     "verbs=get;foo=bar",
+			},
+			wantErrors: []string{AnnotationNamespaceCustomRoleRules, AnnotationNamespaceRoleBindingSubjects},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: tt.annotations}}
+			got, err := Desired(ns, now)
+
+			var gotErrors []string
+			for _, ae := range AnnotationErrors(err) {
+				gotErrors = append(gotErrors, ae.Annotation)
+			}
+			if diff := cmp.Diff(tt.wantErrors, gotErrors); diff != "" {
+				t.Fatalf("Desired() errors mismatch (-want +got):\n%s", diff)
+			}
+			if err != nil {
+				if got.Role != nil || got.RoleBinding != nil {
+					t.Errorf("Desired() returned objects along with error %v", err)
+				}
+				return
+			}
+
+			var wantRole *rbacv1.Role
+			if tt.wantRole {
+				typeMeta, objectMeta := meta("Role")
+				wantRole = &rbacv1.Role{TypeMeta: typeMeta, ObjectMeta: objectMeta, Rules: rules}
+			}
+			if diff := cmp.Diff(wantRole, got.Role); diff != "" {
+				t.Errorf("Desired() role mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantBinding, got.RoleBinding); diff != "" {
+				t.Errorf("Desired() rolebinding mismatch (-want +got):\n%s", diff)
+			}
+			if got.RoleRef != tt.wantRoleRef {
+				t.Errorf("Desired() roleRef = %v, want %v", got.RoleRef, tt.wantRoleRef)
+			}
+			if diff := cmp.Diff(tt.wantExpired, got.Expired); diff != "" {
+				t.Errorf("Desired() expired mismatch (-want +got):\n%s", diff)
+			}
+			if !got.NextExpiry.Equal(tt.wantNextExpiry) {
+				t.Errorf("Desired() nextExpiry = %v, want %v", got.NextExpiry, tt.wantNextExpiry)
+			}
+		})
+	}
+}
+
+func TestAnnotationErrors(t *testing.T) {
+	err := errors.Join(
+		&AnnotationError{Annotation: "a", Err: errors.New("broken")},
+		errors.New("unrelated"),
+		&AnnotationError{Annotation: "b", Err: errors.New("broken")},
+	)
+	var got []string
+	for _, ae := range AnnotationErrors(err) {
+		got = append(got, ae.Annotation)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, got); diff != "" {
+		t.Errorf("AnnotationErrors() mismatch (-want +got):\n%s", diff)
+	}
+	if got := AnnotationErrors(nil); got != nil {
+		t.Errorf("AnnotationErrors(nil) = %v, want nil", got)
+	}
+}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// ActiveSubjects splits the subjects into the ones still granted access at the given point in time and
+// the expired ones. It also returns the next point in time a subject expires, or the zero time if no
+// active subject expires.
+func ActiveSubjects(subjects []TimedSubject, now time.Time) (active, expired []rbacv1.Subject, next time.Time) {
+	active = []rbacv1.Subject{}
+	for _, s := range subjects {
+		if s.Expires == nil {
+			active = append(active, s.Subject)
+			continue
+		}
+		if !now.Before(*s.Expires) {
+			expired = append(expired, s.Subject)
+			continue
+		}
+		active = append(active, s.Subject)
+		if next.IsZero() || s.Expires.Before(next) {
+			next = *s.Expires
+		}
+	}
+	return active, expired, next
+}
+
+// removedSubjects returns all subjects of current that are part of expired
+func removedSubjects(current, expired []rbacv1.Subject) []rbacv1.Subject {
+	removed := []rbacv1.Subject{}
+	for _, c := range current {
+		for _, e := range expired {
+			if c.Kind == e.Kind && c.Name == e.Name && c.Namespace == e.Namespace {
+				removed = append(removed, c)
+				break
+			}
+		}
+	}
+	return removed
+}
@@ -0,0 +1,69 @@
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestActiveSubjects(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	past := now.Add(-time.Hour)
+	soon := now.Add(time.Hour)
+	later := now.Add(24 * time.Hour)
+	tests := []struct {
+		name        string
+		subjects    []TimedSubject
+		wantActive  []rbacv1.Subject
+		wantExpired []rbacv1.Subject
+		wantNext    time.Time
+	}{
+		{
+			name: "without expiry",
+			subjects: []TimedSubject{
+				{Subject: rbacv1.Subject{Kind: "Group", Name: "team-foo"}},
+			},
+			wantActive: []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}},
+		},
+		{
+			name: "mixed",
+			subjects: []TimedSubject{
+				{Subject: rbacv1.Subject{Kind: "User", Name: "expired"}, Expires: &past},
+				{Subject: rbacv1.Subject{Kind: "User", Name: "later"}, Expires: &later},
+				{Subject: rbacv1.Subject{Kind: "User", Name: "soon"}, Expires: &soon},
+				{Subject: rbacv1.Subject{Kind: "Group", Name: "team-foo"}},
+			},
+			wantActive: []rbacv1.Subject{
+				{Kind: "User", Name: "later"},
+				{Kind: "User", Name: "soon"},
+				{Kind: "Group", Name: "team-foo"},
+			},
+			wantExpired: []rbacv1.Subject{{Kind: "User", Name: "expired"}},
+			wantNext:    soon,
+		},
+		{
+			name: "expires right now",
+			subjects: []TimedSubject{
+				{Subject: rbacv1.Subject{Kind: "User", Name: "now"}, Expires: &now},
+			},
+			wantActive:  []rbacv1.Subject{},
+			wantExpired: []rbacv1.Subject{{Kind: "User", Name: "now"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			active, expired, next := ActiveSubjects(tt.subjects, now)
+			if diff := cmp.Diff(tt.wantActive, active); diff != "" {
+				t.Errorf("ActiveSubjects() active mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantExpired, expired); diff != "" {
+				t.Errorf("ActiveSubjects() expired mismatch (-want +got):\n%s", diff)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("ActiveSubjects() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
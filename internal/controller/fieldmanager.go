@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var (
+	ErrUntrustedFieldManager = errors.New("annotation is not managed by a trusted field manager")
+)
+
+// PermissionAnnotations are all annotations that grant permissions in a namespace
+var PermissionAnnotations = []string{
+	AnnotationNamespaceRoleBindingSubjects,
+	AnnotationNamespaceRoleBindingRoleRef,
+	AnnotationNamespaceCustomRoleRules,
+}
+
+// AnnotationFieldManagers returns the names of all field managers owning the given annotation
+// according to the managed fields of the object.
+func AnnotationFieldManagers(obj metav1.Object, annotation string) ([]string, error) {
+	managers := []string{}
+	for _, entry := range obj.GetManagedFields() {
+		annotations, err := ownedAnnotations(entry)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := annotations["f:"+annotation]; ok && !slices.Contains(managers, entry.Manager) {
+			managers = append(managers, entry.Manager)
+		}
+	}
+	return managers, nil
+}
+
+// LastPermissionFieldManager returns the field manager that most recently changed one of the permission annotations.
+// It returns an empty string if none of them has a field manager.
+func LastPermissionFieldManager(obj metav1.Object) (string, error) {
+	var last metav1.ManagedFieldsEntry
+	for _, entry := range obj.GetManagedFields() {
+		annotations, err := ownedAnnotations(entry)
+		if err != nil {
+			return "", err
+		}
+		owns := slices.ContainsFunc(PermissionAnnotations, func(a string) bool {
+			_, ok := annotations["f:"+a]
+			return ok
+		})
+		if !owns {
+			continue
+		}
+		if last.Manager == "" || (entry.Time != nil && (last.Time == nil || last.Time.Before(entry.Time))) {
+			last = entry
+		}
+	}
+	return last.Manager, nil
+}
+
+// ownedAnnotations returns the annotations of a managed fields entry
+func ownedAnnotations(entry metav1.ManagedFieldsEntry) (map[string]any, error) {
+	if entry.FieldsV1 == nil {
+		return nil, nil
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+		return nil, fmt.Errorf("unable to decode managed fields of manager %q: %w", entry.Manager, err)
+	}
+	metadata, ok := fields["f:metadata"].(map[string]any)
+	if !ok {
+		return nil, nil
+	}
+	annotations, _ := metadata["f:annotations"].(map[string]any)
+	return annotations, nil
+}
+
+// CheckFieldManagers makes sure every permission annotation present on the object is exclusively
+// owned by one of the trusted field managers. If trusted is empty, every field manager is accepted.
+func CheckFieldManagers(obj metav1.Object, trusted []string) error {
+	return checkFieldManagers(obj, trusted, PermissionAnnotations)
+}
+
+// checkFieldManagers makes sure every given annotation present on the object is exclusively
+// owned by one of the trusted field managers
+func checkFieldManagers(obj metav1.Object, trusted []string, annotations []string) error {
+	if len(trusted) == 0 {
+		return nil
+	}
+	for _, annotation := range annotations {
+		if _, ok := obj.GetAnnotations()[annotation]; !ok {
+			continue
+		}
+		managers, err := AnnotationFieldManagers(obj, annotation)
+		if err != nil {
+			return err
+		}
+		if len(managers) == 0 {
+			return fmt.Errorf("%w: %q has no field manager", ErrUntrustedFieldManager, annotation)
+		}
+		for _, manager := range managers {
+			if !slices.Contains(trusted, manager) {
+				return fmt.Errorf("%w: %q is managed by %q", ErrUntrustedFieldManager, annotation, manager)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func managedFieldsEntry(manager, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:    manager,
+		Operation:  metav1.ManagedFieldsOperationApply,
+		FieldsType: "FieldsV1",
+		FieldsV1:   &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func TestAnnotationFieldManagers(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test",
+			ManagedFields: []metav1.ManagedFieldsEntry{
+				managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}},"f:labels":{"f:ns.tagesspiegel.de/permission-control":{}}}}`),
+				managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/custom-role-rules":{}}}}`),
+				managedFieldsEntry("kube-controller-manager", `{"f:status":{"f:phase":{}}}`),
+			},
+		},
+	}
+	tests := []struct {
+		name       string
+		annotation string
+		want       []string
+	}{
+		{
+			name:       "owned by argocd",
+			annotation: AnnotationNamespaceRoleBindingSubjects,
+			want:       []string{"argocd-controller"},
+		},
+		{
+			name:       "owned by kubectl",
+			annotation: AnnotationNamespaceCustomRoleRules,
+			want:       []string{"kubectl-annotate"},
+		},
+		{
+			name:       "not owned",
+			annotation: AnnotationNamespaceRoleBindingRoleRef,
+			want:       []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := AnnotationFieldManagers(ns, tt.annotation)
+			if err != nil {
+				t.Fatalf("AnnotationFieldManagers() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("AnnotationFieldManagers() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestCheckFieldManagers(t *testing.T) {
+	tests := []struct {
+		name    string
+		ns      *corev1.Namespace
+		trusted []string
+		wantErr bool
+	}{
+		{
+			name: "no trusted managers configured",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=foo"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+				},
+			}},
+			trusted: nil,
+			wantErr: false,
+		},
+		{
+			name: "trusted manager",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=foo"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+				},
+			}},
+			trusted: []string{"argocd-controller"},
+			wantErr: false,
+		},
+		{
+			name: "untrusted manager",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=foo"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+				},
+			}},
+			trusted: []string{"argocd-controller"},
+			wantErr: true,
+		},
+		{
+			name: "shared with untrusted manager",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=foo"},
+				ManagedFields: []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+					managedFieldsEntry("kubectl", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+				},
+			}},
+			trusted: []string{"argocd-controller"},
+			wantErr: true,
+		},
+		{
+			name: "annotation without managed fields",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{AnnotationNamespaceCustomRoleRules: "verbs=get;apiGroups=;resources=pods"},
+			}},
+			trusted: []string{"argocd-controller"},
+			wantErr: true,
+		},
+		{
+			name:    "no permission annotations",
+			ns:      &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{}},
+			trusted: []string{"argocd-controller"},
+			wantErr: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckFieldManagers(tt.ns, tt.trusted)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckFieldManagers() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrUntrustedFieldManager) {
+				t.Errorf("CheckFieldManagers() error = %v, want %v", err, ErrUntrustedFieldManager)
+			}
+		})
+	}
+}
+
+func TestLastPermissionFieldManager(t *testing.T) {
+	older, newer := metav1.NewTime(time.Now().Add(-time.Hour)), metav1.NewTime(time.Now())
+	argocd := managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`)
+	argocd.Time = &older
+	kubectl := managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/custom-role-rules":{}}}}`)
+	kubectl.Time = &newer
+	status := managedFieldsEntry("kube-controller-manager", `{"f:status":{"f:phase":{}}}`)
+	status.Time = &newer
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:          "test",
+		ManagedFields: []metav1.ManagedFieldsEntry{kubectl, status, argocd},
+	}}
+	got, err := LastPermissionFieldManager(ns)
+	if err != nil {
+		t.Fatalf("LastPermissionFieldManager() error = %v", err)
+	}
+	if got != "kubectl-annotate" {
+		t.Errorf("LastPermissionFieldManager() = %v, want %v", got, "kubectl-annotate")
+	}
+}
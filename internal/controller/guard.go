@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+var (
+	ErrProtectedNamespace = errors.New("namespace is protected")
+	ErrDeniedSubject      = errors.New("subject is denied")
+)
+
+// Guard holds the namespaces and subjects the controller must never grant permissions for.
+// A nil Guard protects nothing.
+type Guard struct {
+	// ProtectedNamespaces is a list of shell patterns (see path.Match) of namespace names that are never reconciled
+	ProtectedNamespaces []string
+	// DeniedSubjects is a list of subjects that must never be bound. Empty fields match every value,
+	// all other fields are interpreted as shell patterns (see path.Match).
+	DeniedSubjects []rbacv1.Subject
+}
+
+// NewGuard creates a Guard from a list of namespace patterns and a subject denylist
+// in the same format as the `ns.tagesspiegel.de/rolebinding-subjects` annotation.
+//
+// Example:
+//
+//	guard, err := NewGuard([]string{"kube-*", "argocd"}, "kind=Group;name=system:masters,kind=User;name=system:anonymous")
+//	if err != nil {
+//		// handle error
+//	}
+func NewGuard(protectedNamespaces []string, deniedSubjects string) (*Guard, error) {
+	g := &Guard{}
+	for _, p := range protectedNamespaces {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("invalid protected namespace pattern %q: %w", p, err)
+		}
+		g.ProtectedNamespaces = append(g.ProtectedNamespaces, p)
+	}
+	if deniedSubjects != "" {
+		subjects, err := ParseRoleBindingSubjects(deniedSubjects)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range subjects {
+			for _, p := range []string{s.Kind, s.APIGroup, s.Name, s.Namespace} {
+				if _, err := path.Match(p, ""); err != nil {
+					return nil, fmt.Errorf("invalid denied subject pattern %q: %w", p, err)
+				}
+			}
+		}
+		g.DeniedSubjects = subjects
+	}
+	return g, nil
+}
+
+// IsProtectedNamespace reports whether the given namespace matches one of the protected namespace patterns
+func (g *Guard) IsProtectedNamespace(name string) bool {
+	if g == nil {
+		return false
+	}
+	for _, p := range g.ProtectedNamespaces {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckSubjects returns an error wrapping ErrDeniedSubject for the first subject on the denylist
+func (g *Guard) CheckSubjects(subjects []rbacv1.Subject) error {
+	if g == nil {
+		return nil
+	}
+	for idx, s := range subjects {
+		for _, denied := range g.DeniedSubjects {
+			if matchSubject(denied, s) {
+				return fmt.Errorf("%w at index %d: kind=%s;name=%s;namespace=%s", ErrDeniedSubject, idx, s.Kind, s.Name, s.Namespace)
+			}
+		}
+	}
+	return nil
+}
+
+func matchSubject(pattern, s rbacv1.Subject) bool {
+	return matchField(pattern.Kind, s.Kind) &&
+		matchField(pattern.APIGroup, s.APIGroup) &&
+		matchField(pattern.Name, s.Name) &&
+		matchField(pattern.Namespace, s.Namespace)
+}
+
+func matchField(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestGuard_IsProtectedNamespace(t *testing.T) {
+	type fields struct {
+		ProtectedNamespaces []string
+	}
+	tests := []struct {
+		name      string
+		fields    fields
+		namespace string
+		want      bool
+	}{
+		{
+			name:      "exact match",
+			fields:    fields{ProtectedNamespaces: []string{"argocd"}},
+			namespace: "argocd",
+			want:      true,
+		},
+		{
+			name:      "pattern match",
+			fields:    fields{ProtectedNamespaces: []string{"kube-*"}},
+			namespace: "kube-system",
+			want:      true,
+		},
+		{
+			name:      "no match",
+			fields:    fields{ProtectedNamespaces: []string{"kube-*", "argocd"}},
+			namespace: "feature-foo",
+			want:      false,
+		},
+		{
+			name:      "no patterns",
+			fields:    fields{},
+			namespace: "kube-system",
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Guard{
+				ProtectedNamespaces: tt.fields.ProtectedNamespaces,
+			}
+			if got := g.IsProtectedNamespace(tt.namespace); got != tt.want {
+				t.Errorf("Guard.IsProtectedNamespace() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGuard_CheckSubjects(t *testing.T) {
+	g, err := NewGuard(nil, "kind=Group;name=system:masters,kind=User;name=system:*,kind=ServiceAccount;namespace=kube-system")
+	if err != nil {
+		t.Fatalf("NewGuard() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		subjects []rbacv1.Subject
+		wantErr  bool
+	}{
+		{
+			name:     "allowed subjects",
+			subjects: []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}, {Kind: "ServiceAccount", Name: "default", Namespace: "foo"}},
+			wantErr:  false,
+		},
+		{
+			name:     "exact match",
+			subjects: []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}, {Kind: "Group", Name: "system:masters"}},
+			wantErr:  true,
+		},
+		{
+			name:     "pattern match",
+			subjects: []rbacv1.Subject{{Kind: "User", Name: "system:anonymous"}},
+			wantErr:  true,
+		},
+		{
+			name:     "omitted property matches everything",
+			subjects: []rbacv1.Subject{{Kind: "ServiceAccount", Name: "foo", Namespace: "kube-system"}},
+			wantErr:  true,
+		},
+		{
+			name:     "kind has to match",
+			subjects: []rbacv1.Subject{{Kind: "User", Name: "system:masters"}, {Kind: "Group", Name: "system:anonymous"}},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := g.CheckSubjects(tt.subjects)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Guard.CheckSubjects() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrDeniedSubject) {
+				t.Errorf("Guard.CheckSubjects() error = %v, want %v", err, ErrDeniedSubject)
+			}
+		})
+	}
+}
+
+func TestNewGuard(t *testing.T) {
+	type args struct {
+		protectedNamespaces []string
+		deniedSubjects      string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name:    "valid",
+			args:    args{protectedNamespaces: []string{"kube-*"}, deniedSubjects: "kind=Group;name=system:masters"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid namespace pattern",
+			args:    args{protectedNamespaces: []string{"kube-["}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid subject key",
+			args:    args{deniedSubjects: "kind=Group;foo=bar"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGuard(tt.args.protectedNamespaces, tt.args.deniedSubjects); (err != nil) != tt.wantErr {
+				t.Errorf("NewGuard() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
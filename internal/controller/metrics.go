@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	strc "github.com/tagesspiegel/kubernetes-namespace-permission-manager/utils/strings"
+)
+
+const metricsNamespace = "namespace_permission_manager"
+
+// OperationDeleted is reported for deleted objects, as controllerutil has no OperationResult for deletions
+const OperationDeleted controllerutil.OperationResult = "deleted"
+
+var (
+	annotationParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "annotation_parse_failures_total",
+		Help:      "Number of permission annotations that could not be parsed, by annotation and error",
+	}, []string{"annotation", "error"})
+	objectOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "object_operations_total",
+		Help:      "Number of reconciled Roles and RoleBindings, by kind and operation",
+	}, []string{"kind", "operation"})
+	plannedOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "planned_operations_total",
+		Help:      "Number of Roles and RoleBindings reconciled in dry-run mode, by kind and planned operation",
+	}, []string{"kind", "operation"})
+	applyConflicts = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "apply_conflicts_total",
+		Help:      "Number of Roles and RoleBindings whose fields the controller took over from other field managers, by kind",
+	}, []string{"kind"})
+	breakGlassActivations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "break_glass_activations_total",
+		Help:      "Number of break-glass accesses granted, by namespace",
+	}, []string{"namespace"})
+	breakGlassActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "break_glass_active",
+		Help:      "Number of break-glass accesses currently granted",
+	})
+	sharedClusterRoles = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "shared_cluster_roles",
+		Help:      "Number of shared ClusterRoles holding the custom rules of namespaces, as of the last garbage collection",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(annotationParseFailures, objectOperations, plannedOperations, applyConflicts, breakGlassActivations, breakGlassActive,
+		sharedClusterRoles)
+}
+
+// parseErrors maps the errors returned by the annotation parsers to their metric label.
+// More specific errors come first, as they may wrap the generic ones.
+var parseErrors = []struct {
+	err   error
+	label string
+}{
+	{ErrInvalidExpiry, "ErrInvalidExpiry"},
+	{ErrInvalidKeyInRole, "ErrInvalidKeyInRole"},
+	{ErrInvalidKeyInRoleRef, "ErrInvalidKeyInRoleRef"},
+	{ErrInvalidKeyInCustomRole, "ErrInvalidKeyInCustomRole"},
+	{strc.ErrInvalidKeyValueString, "ErrInvalidKeyValueString"},
+}
+
+// parseErrorLabel returns the metric label of a parse error
+func parseErrorLabel(err error) string {
+	for _, e := range parseErrors {
+		if errors.Is(err, e.err) {
+			return e.label
+		}
+	}
+	return "Unknown"
+}
+
+// recordParseFailure counts a failure to parse the given annotation
+func recordParseFailure(annotation string, err error) {
+	annotationParseFailures.WithLabelValues(annotation, parseErrorLabel(err)).Inc()
+}
+
+// recordOperation counts the outcome of reconciling a Role or RoleBinding
+func recordOperation(kind string, result controllerutil.OperationResult) {
+	objectOperations.WithLabelValues(kind, string(result)).Inc()
+}
+
+// recordApplyConflict counts a conflict with other field managers resolved by forcing the apply
+func recordApplyConflict(kind string) {
+	applyConflicts.WithLabelValues(kind).Inc()
+}
+
+// recordPlannedOperation counts the outcome of reconciling a Role or RoleBinding in dry-run mode
+func recordPlannedOperation(kind string, result controllerutil.OperationResult) {
+	plannedOperations.WithLabelValues(kind, string(result)).Inc()
+}
+
+// ManagedNamespaceCollector reports the managed namespaces and the subjects granted through their annotations.
+// It reads from the given reader on every scrape, which should be the cache of the manager.
+type ManagedNamespaceCollector struct {
+	Reader client.Reader
+	// Guard excludes protected namespaces. A nil Guard protects nothing.
+	Guard *Guard
+	// TrustedFieldManagers only counts pauses written by these field managers as paused. If empty, every pause counts.
+	TrustedFieldManagers []string
+
+	namespaces *prometheus.Desc
+	paused     *prometheus.Desc
+	subjects   *prometheus.Desc
+	invalid    *prometheus.Desc
+}
+
+var _ prometheus.Collector = &ManagedNamespaceCollector{}
+
+// NewManagedNamespaceCollector returns a collector reading the namespaces from the given reader
+func NewManagedNamespaceCollector(reader client.Reader, guard *Guard) *ManagedNamespaceCollector {
+	return &ManagedNamespaceCollector{
+		Reader: reader,
+		Guard:  guard,
+		namespaces: prometheus.NewDesc(metricsNamespace+"_managed_namespaces",
+			"Number of namespaces managed by the controller", nil, nil),
+		paused: prometheus.NewDesc(metricsNamespace+"_paused_namespaces",
+			"Number of managed namespaces whose reconciliation is paused", nil, nil),
+		subjects: prometheus.NewDesc(metricsNamespace+"_subjects_granted",
+			"Number of subjects granted access through the namespace annotations, by kind", []string{"kind"}, nil),
+		invalid: prometheus.NewDesc(metricsNamespace+"_invalid_annotations",
+			"Number of managed namespaces whose annotation currently fails to parse, by annotation", []string{"annotation"}, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *ManagedNamespaceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.namespaces
+	ch <- c.paused
+	ch <- c.subjects
+	ch <- c.invalid
+}
+
+// Collect implements prometheus.Collector
+func (c *ManagedNamespaceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	list := &corev1.NamespaceList{}
+	if err := c.Reader.List(ctx, list, client.HasLabels{LabelNamespacePermissionControl}); err != nil {
+		logf.Log.WithName("metrics").Error(err, "unable to list managed namespaces")
+		return
+	}
+	managed, paused := 0, 0
+	// always report the common kinds, so they don't disappear when no subject of a kind is left
+	subjects := map[string]int{"User": 0, "Group": 0, "ServiceAccount": 0}
+	invalid := map[string]int{
+		AnnotationNamespaceRoleBindingRoleRef:  0,
+		AnnotationNamespaceCustomRoleRules:     0,
+		AnnotationNamespaceRoleBindingSubjects: 0,
+	}
+	now := time.Now()
+	for _, ns := range list.Items {
+		if c.Guard.IsProtectedNamespace(ns.Name) {
+			continue
+		}
+		managed++
+		if pause, _ := TrustedPauseOf(&ns, now, c.TrustedFieldManagers); pause.Paused {
+			paused++
+		}
+		// the valid subjects are counted even if another annotation is invalid
+		desired, err := Desired(&ns, now)
+		for _, aerr := range AnnotationErrors(err) {
+			invalid[aerr.Annotation]++
+		}
+		active, _, _ := ActiveSubjects(desired.Subjects, now)
+		for _, s := range active {
+			subjects[s.Kind]++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(c.namespaces, prometheus.GaugeValue, float64(managed))
+	ch <- prometheus.MustNewConstMetric(c.paused, prometheus.GaugeValue, float64(paused))
+	for kind, n := range subjects {
+		ch <- prometheus.MustNewConstMetric(c.subjects, prometheus.GaugeValue, float64(n), kind)
+	}
+	for annotation, n := range invalid {
+		ch <- prometheus.MustNewConstMetric(c.invalid, prometheus.GaugeValue, float64(n), annotation)
+	}
+}
@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	strc "github.com/tagesspiegel/kubernetes-namespace-permission-manager/utils/strings"
+)
+
+func Test_parseErrorLabel(t *testing.T) {
+	_, subjectErr := ParseTimedRoleBindingSubjects("kind=User;foo=bar")
+	_, expiryErr := ParseTimedRoleBindingSubjects("kind=User;name=foo;expires=tomorrow")
+	_, customRoleErr := ParseCustomRole("foo=bar")
+	_, kvErr := ParseRoleBindingRoleRef("kind")
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "invalid key in subjects", err: subjectErr, want: "ErrInvalidKeyInRole"},
+		{name: "invalid expiry", err: expiryErr, want: "ErrInvalidExpiry"},
+		{name: "invalid key in custom role", err: customRoleErr, want: "ErrInvalidKeyInCustomRole"},
+		{name: "invalid key value string", err: kvErr, want: "ErrInvalidKeyValueString"},
+		{name: "wrapped error", err: fmt.Errorf("foo: %w", strc.ErrInvalidKeyValueString), want: "ErrInvalidKeyValueString"},
+		{name: "unknown error", err: fmt.Errorf("foo"), want: "Unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseErrorLabel(tt.err); got != tt.want {
+				t.Errorf("parseErrorLabel() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestManagedNamespaceCollector(t *testing.T) {
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-a",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a,kind=User;name=jane,kind=User;name=old;expires=2000-01-01T00:00:00Z",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        "team-b",
+			Labels:      map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{AnnotationReconcilePaused: "true"},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "team-c",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceCustomRoleRules:     "verbs=get;foo=bar",
+				AnnotationNamespaceRoleBindingSubjects: "kind=ServiceAccount;name=ci;namespace=team-c",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   "kube-system",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+			},
+		}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+	).Build()
+
+	collector := NewManagedNamespaceCollector(c, &Guard{ProtectedNamespaces: []string{"kube-*"}})
+	expected := `
+# HELP namespace_permission_manager_managed_namespaces Number of namespaces managed by the controller
+# TYPE namespace_permission_manager_managed_namespaces gauge
+namespace_permission_manager_managed_namespaces 3
+# HELP namespace_permission_manager_paused_namespaces Number of managed namespaces whose reconciliation is paused
+# TYPE namespace_permission_manager_paused_namespaces gauge
+namespace_permission_manager_paused_namespaces 1
+# HELP namespace_permission_manager_subjects_granted Number of subjects granted access through the namespace annotations, by kind
+# TYPE namespace_permission_manager_subjects_granted gauge
+namespace_permission_manager_subjects_granted{kind="Group"} 1
+namespace_permission_manager_subjects_granted{kind="ServiceAccount"} 1
+namespace_permission_manager_subjects_granted{kind="User"} 1
+# HELP namespace_permission_manager_invalid_annotations Number of managed namespaces whose annotation currently fails to parse, by annotation
+# TYPE namespace_permission_manager_invalid_annotations gauge
+namespace_permission_manager_invalid_annotations{annotation="ns.tagesspiegel.de/custom-role-rules"} 1
+namespace_permission_manager_invalid_annotations{annotation="ns.tagesspiegel.de/rolebinding-roleref"} 0
+namespace_permission_manager_invalid_annotations{annotation="ns.tagesspiegel.de/rolebinding-subjects"} 0
+`
+	if err := testutil.CollectAndCompare(collector, strings.NewReader(expected)); err != nil {
+		t.Error(err)
+	}
+}
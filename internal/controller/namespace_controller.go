@@ -18,21 +18,61 @@ package controller
 
 import (
 	"context"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	corev1 "k8s.io/api/core/v1"
 	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/health"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/policy"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/tracing"
 )
 
 // NamespaceReconciler reconciles a Namespace object
 type NamespaceReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// Policy is evaluated against the parsed permissions of every namespace.
+	// A nil Policy allows all permissions.
+	Policy *policy.Evaluator
+	// Guard protects namespaces and subjects from ever being managed.
+	// A nil Guard protects nothing.
+	Guard *Guard
+	// TrustedFieldManagers are the only field managers allowed to set the permission annotations.
+	// If empty, annotations written by any field manager are honoured.
+	TrustedFieldManagers []string
+	// Audit records every change of a managed Role or RoleBinding. A nil Audit records nothing.
+	Audit *audit.Logger
+	// Stalls tracks running reconciliations for the liveness check. A nil Stalls tracks nothing.
+	Stalls *health.StallDetector
+	// APIReader reads namespaces missing from the cache, which only holds labelled namespaces.
+	// It is needed to clean up namespaces that lost the label. A nil APIReader relies on the Client.
+	APIReader client.Reader
+	// Shard restricts the reconciled namespaces to the ones owned by the replica.
+	// A nil Shard reconciles all namespaces on the leader.
+	Shard Shard
+	// SharedRoles binds namespaces with custom rules to a ClusterRole shared by all namespaces with the same rules,
+	// instead of creating a Role per namespace. Unused shared ClusterRoles are deleted by the SharedRoleCollector.
+	SharedRoles bool
+	// DryRun only plans changes. The Client is expected to be a dry-run client, so no change is persisted.
+	DryRun bool
 }
 
 //+kubebuilder:rbac:groups="*",resources="*",verbs="*"
@@ -42,24 +82,54 @@ const (
 	LabelManagedBy                  = "app.kubernetes.io/managed-by"
 	LabelNamespaceName              = "ns.tagesspiegel.de/source-namespace"
 
+	// ManagedByValue is the value of LabelManagedBy on all objects created by the controller
+	ManagedByValue = "namespace-permission-controller"
+
 	AnnotationNamespaceRoleBindingSubjects = "ns.tagesspiegel.de/rolebinding-subjects"
 	AnnotationNamespaceRoleBindingRoleRef  = "ns.tagesspiegel.de/rolebinding-roleref"
 	AnnotationNamespaceCustomRoleRules     = "ns.tagesspiegel.de/custom-role-rules"
+
+	EventReasonPolicyDenied  = "PolicyDenied"
+	EventReasonSubjectDenied = "SubjectDenied"
+	EventReasonUntrusted     = "UntrustedFieldManager"
+	EventReasonAccessExpired = "AccessExpired"
 )
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
 func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	defer r.Stalls.Begin()()
+	ctx, span := tracing.Tracer().Start(ctx, "NamespaceReconciler.Reconcile",
+		trace.WithAttributes(AttributeNamespace.String(req.Name)))
+	result, err := r.reconcile(ctx, req)
+	tracing.End(span, err)
+	return result, err
+}
+
+func (r *NamespaceReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logx := log.FromContext(ctx)
 
+	// namespaces of other shards are reconciled by other replicas
+	if r.Shard != nil && !r.Shard.Owns(req.Name) {
+		logx.V(100).Info("namespace belongs to another shard, ignoring")
+		setOutcome(ctx, OutcomeOtherShard)
+		return ctrl.Result{}, nil
+	}
+
 	ns := &corev1.Namespace{}
 	err := r.Client.Get(ctx, req.NamespacedName, ns)
+	if errors.IsNotFound(err) && r.APIReader != nil {
+		// the cache only holds labelled namespaces, the namespace may have lost the label
+		err = r.APIReader.Get(ctx, req.NamespacedName, ns)
+	}
 	if err != nil && !errors.IsNotFound(err) {
 		logx.Error(err, "unable to fetch Namespace")
+		setOutcome(ctx, OutcomeReadError)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	if errors.IsNotFound(err) {
 		logx.V(100).Info("namespace not found, ignoring")
+		setOutcome(ctx, OutcomeIgnored)
 		return ctrl.Result{}, nil
 	}
 
@@ -67,101 +137,242 @@ func (r *NamespaceReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	if ns.Status.Phase != corev1.NamespaceActive {
 		// we only care about active namespaces for now
 		logx.V(100).Info("namespace not active, ignoring")
+		setOutcome(ctx, OutcomeIgnored)
+		return ctrl.Result{}, nil
+	}
+
+	// never touch protected namespaces, even if they carry our label
+	if r.Guard.IsProtectedNamespace(ns.Name) {
+		logx.Info("namespace is protected, ignoring")
+		setOutcome(ctx, OutcomeProtected)
 		return ctrl.Result{}, nil
 	}
 
 	// check if the namespace has our label
+	now := time.Now()
 	_, ok := ns.Labels[LabelNamespacePermissionControl]
 	if !ok {
+		// objects labelled as managed in a namespace that was never managed, e.g. by nspm import, are kept
+		if ns.Annotations[AnnotationManaged] != "true" {
+			logx.V(100).Info("namespace has no label, ignoring")
+			setOutcome(ctx, OutcomeIgnored)
+			return ctrl.Result{}, nil
+		}
+		// a paused namespace keeps its objects even if it lost the label
+		if pause, _ := TrustedPauseOf(ns, now, r.TrustedFieldManagers); pause.Paused {
+			logx.V(100).Info("namespace has no label and is paused, ignoring")
+			setOutcome(ctx, OutcomePaused)
+			return ctrl.Result{}, nil
+		}
+		// remove the objects created while the namespace carried the label
+		cleaned, err := r.cleanup(ctx, ns)
+		if err == nil {
+			err = r.unmarkManaged(ctx, ns)
+		}
+		if err != nil {
+			logx.Error(err, "unable to clean up namespace")
+			setOutcome(ctx, OutcomeApplyError)
+			return ctrl.Result{}, err
+		}
+		if cleaned {
+			logx.Info("namespace lost its label, removed the managed role and rolebinding")
+			setOutcome(ctx, OutcomeCleanedUp)
+			return ctrl.Result{}, nil
+		}
 		logx.V(100).Info("namespace has no label, ignoring")
 		// if not, we don't care about it
+		setOutcome(ctx, OutcomeIgnored)
 		return ctrl.Result{}, nil
 	}
 
-	roleRef := rbacv1.RoleRef{}
+	// remember that the namespace is managed, so its objects are cleaned up once it loses the label
+	if err := r.markManaged(ctx, ns); err != nil {
+		logx.Error(err, "unable to mark namespace as managed")
+		setOutcome(ctx, OutcomeApplyError)
+		return ctrl.Result{}, err
+	}
 
-	// check if the namespace has a role ref
-	rf, ok := ns.Annotations[AnnotationNamespaceRoleBindingRoleRef]
-	if ok {
-		rfn, err := ParseRoleBindingRoleRef(rf)
-		if err != nil {
+	// leave the Role and RoleBinding alone while reconciliation is paused
+	pause, err := TrustedPauseOf(ns, now, r.TrustedFieldManagers)
+	if err != nil {
+		logx.Error(err, "ignoring pause annotation")
+		r.Recorder.Event(ns, corev1.EventTypeWarning, EventReasonInvalidPause, err.Error())
+	}
+	if setPauseCondition(ns, pause, now) {
+		if err := r.Status().Update(ctx, ns); err != nil {
+			logx.Error(err, "unable to update namespace status")
+			setOutcome(ctx, OutcomeApplyError)
 			return ctrl.Result{}, err
 		}
-		roleRef = rfn
+	}
+	if pause.Paused {
+		logx.Info("reconciliation is paused, ignoring", "until", pause.Until)
+		setOutcome(ctx, OutcomePaused)
+		// come back once the pause expires
+		if !pause.Until.IsZero() {
+			return ctrl.Result{RequeueAfter: pause.Until.Sub(now)}, nil
+		}
+		return ctrl.Result{}, nil
 	}
 
-	// check if the namespace has custom rules
-	cr, ok := ns.Annotations[AnnotationNamespaceCustomRoleRules]
-	if ok {
-		// parse the role rules
-		rules, err := ParseCustomRole(cr)
-		if err != nil {
-			logx.Error(err, "unable to parse role rules")
+	// only honour annotations written by trusted field managers
+	if err := CheckFieldManagers(ns, r.TrustedFieldManagers); err != nil {
+		logx.Error(err, "refusing to apply permission annotations")
+		r.Recorder.Event(ns, corev1.EventTypeWarning, EventReasonUntrusted, err.Error())
+		setOutcome(ctx, OutcomeUntrusted)
+		return ctrl.Result{}, nil
+	}
+
+	// compute the desired Role and RoleBinding from the annotations
+	desired, err := DesiredContext(ctx, ns, now)
+	if err != nil {
+		requeue := false
+		for _, ae := range AnnotationErrors(err) {
+			recordParseFailure(ae.Annotation, ae.Err)
+			// an invalid role ref is retried, invalid rules and subjects wait for the annotations to change
+			requeue = requeue || ae.Annotation == AnnotationNamespaceRoleBindingRoleRef
+		}
+		setOutcome(ctx, OutcomeParseError)
+		logx.Error(err, "unable to parse permission annotations")
+		if requeue {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	var subjects []rbacv1.Subject
+	if desired.RoleBinding != nil {
+		subjects = desired.RoleBinding.Subjects
+		// make sure none of the subjects is on the denylist
+		if err := r.Guard.CheckSubjects(subjects); err != nil {
+			logx.Error(err, "subjects rejected")
+			r.Recorder.Event(ns, corev1.EventTypeWarning, EventReasonSubjectDenied, err.Error())
+			setOutcome(ctx, OutcomeSubjectDenied)
 			return ctrl.Result{}, nil
 		}
-		// create a role
-		role := &rbacv1.Role{
-			ObjectMeta: ctrl.ObjectMeta{
-				Name:      ns.Name,
-				Namespace: ns.Name,
-				Labels: map[string]string{
-					LabelManagedBy:     "namespace-permission-controller",
-					LabelNamespaceName: ns.Name,
-				},
-			},
-		}
-		rslt, err := ctrl.CreateOrUpdate(ctx, r.Client, role, func() error {
-			role.Rules = rules
-			return nil
-		})
+	}
+
+	// check if the requested permissions are allowed by the configured policies
+	err = r.Policy.Evaluate(policy.Input{
+		Namespace: ns,
+		Subjects:  subjects,
+		RoleRef:   desired.RoleRef,
+		Rules:     desired.Rules,
+	})
+	if err != nil {
+		logx.Error(err, "permissions rejected by policy")
+		r.Recorder.Event(ns, corev1.EventTypeWarning, EventReasonPolicyDenied, err.Error())
+		setOutcome(ctx, OutcomePolicyDenied)
+		return ctrl.Result{}, nil
+	}
+
+	// identical custom rules are deduplicated into one ClusterRole
+	if r.SharedRoles {
+		desired.ShareRole()
+	}
+
+	if desired.ClusterRole != nil {
+		rslt, err := applyClusterRole(ctx, r.Client, r.changes(), desired.ClusterRole, namespaceOrigin(ns))
 		if err != nil {
-			logx.Error(err, "unable to create or update role")
+			logx.Error(err, "unable to apply shared clusterrole")
+			setOutcome(ctx, OutcomeApplyError)
 			return ctrl.Result{}, nil
 		}
-		logx.V(80).Info("result for reconciliation for role binding", "result", rslt)
-		roleRef.Kind = "Role"
-		roleRef.APIGroup = "rbac.authorization.k8s.io"
-		roleRef.Name = role.GetName()
+		logx.V(80).Info("result for reconciliation for shared clusterrole", "result", rslt, "name", desired.ClusterRole.Name)
 	}
 
-	rbSubjects, ok := ns.Annotations[AnnotationNamespaceRoleBindingSubjects]
-	if ok {
-		// parse the role rules
-		subjects, err := ParseRoleBindingSubjects(rbSubjects)
+	if desired.Role != nil {
+		rslt, err := applyRole(ctx, r.Client, r.changes(), desired.Role, namespaceOrigin(ns))
 		if err != nil {
-			logx.Error(err, "unable to parse role rules")
+			logx.Error(err, "unable to apply role")
+			setOutcome(ctx, OutcomeApplyError)
 			return ctrl.Result{}, nil
 		}
+		logx.V(80).Info("result for reconciliation for role", "result", rslt)
+	}
+
+	if desired.RoleBinding != nil {
 		// create a rb
-		rb := &rbacv1.RoleBinding{
-			ObjectMeta: ctrl.ObjectMeta{
-				Name:      ns.Name,
-				Namespace: ns.Name,
-				Labels: map[string]string{
-					LabelManagedBy:     "namespace-permission-controller",
-					LabelNamespaceName: ns.Name,
-				},
-			},
-		}
-		rslt, err := ctrl.CreateOrUpdate(ctx, r.Client, rb, func() error {
-			rb.Subjects = subjects
-			rb.RoleRef = roleRef
-			return nil
+		previous, rslt, err := applyRoleBinding(ctx, r.Client, r.Scheme, r.changes(), RoleBindingSpec{
+			Name:      desired.RoleBinding.Name,
+			Namespace: desired.RoleBinding.Namespace,
+			RoleRef:   desired.RoleBinding.RoleRef,
+			Subjects:  desired.RoleBinding.Subjects,
+			Origin:    namespaceOrigin(ns),
 		})
 		if err != nil {
-			logx.Error(err, "unable to create or update rolebinding")
+			logx.Error(err, "unable to apply rolebinding")
+			setOutcome(ctx, OutcomeApplyError)
 			return ctrl.Result{}, nil
 		}
 		logx.V(80).Info("result for reconciliation for role binding", "result", rslt)
+		for _, s := range removedSubjects(previous, desired.Expired) {
+			logx.Info("access expired", "kind", s.Kind, "name", s.Name, "namespace", s.Namespace)
+			if r.DryRun {
+				// the subject is still bound
+				continue
+			}
+			r.Recorder.Eventf(ns, corev1.EventTypeNormal, EventReasonAccessExpired,
+				"access of %s %q expired and was removed from rolebinding %q", s.Kind, s.Name, ns.Name)
+		}
 	}
 
+	if r.SharedRoles {
+		// the Role created before the rules were shared isn't bound anymore
+		if _, err := r.removeRole(ctx, ns); err != nil {
+			logx.Error(err, "unable to remove role replaced by shared clusterrole")
+			setOutcome(ctx, OutcomeApplyError)
+			return ctrl.Result{}, err
+		}
+	}
+
+	setOutcome(ctx, OutcomeApplied)
+	// come back once the next subject expires
+	if !desired.NextExpiry.IsZero() {
+		return ctrl.Result{RequeueAfter: time.Until(desired.NextExpiry)}, nil
+	}
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *NamespaceReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	bldr := ctrl.NewControllerManagedBy(mgr)
+	opts := stallOptions(r.Stalls)
+	if r.Shard != nil {
+		// every replica reconciles its own shard
+		events := make(chan event.GenericEvent)
+		if err := mgr.Add(&rebalancer{reader: mgr.GetCache(), shard: r.Shard, events: events}); err != nil {
+			return err
+		}
+		bldr = bldr.WatchesRawSource(source.Channel(events, &handler.EnqueueRequestForObject{}))
+		opts.NeedLeaderElection = ptr.To(false)
+	}
+	return bldr.
+		WithOptions(opts).
 		// we only expect to be called for namespaces with our label
-		For(&corev1.Namespace{}, builder.WithPredicates(&LabelChecker{ExpectedLabel: LabelNamespacePermissionControl})).
+		// and never for protected namespaces
+		For(&corev1.Namespace{}, builder.WithPredicates(
+			&LabelChecker{ExpectedLabel: LabelNamespacePermissionControl},
+			&ProtectedNamespaceFilter{Guard: r.Guard},
+			&NamespaceChangedPredicate{TrustedFieldManagers: r.TrustedFieldManagers},
+		)).
+		// changes of managed objects bring them back to the desired state,
+		// and on startup they find namespaces that lost the label in the meantime
+		Watches(&rbacv1.Role{}, handler.EnqueueRequestsFromMapFunc(sourceNamespace),
+			builder.WithPredicates(&LabelChecker{ExpectedLabel: LabelNamespaceName})).
+		Watches(&rbacv1.RoleBinding{}, handler.EnqueueRequestsFromMapFunc(sourceNamespace),
+			builder.WithPredicates(&LabelChecker{ExpectedLabel: LabelNamespaceName})).
+		Watches(&rbacv1.ClusterRole{}, handler.EnqueueRequestsFromMapFunc(r.sharedRoleUsers),
+			builder.WithPredicates(&LabelChecker{ExpectedLabel: LabelSharedRules})).
 		Complete(r)
 }
+
+// stallOptions returns the controller options letting the StallDetector watch the work queue of a controller
+func stallOptions(stalls *health.StallDetector) controller.Options {
+	return controller.Options{
+		NewQueue: func(name string, rateLimiter workqueue.TypedRateLimiter[reconcile.Request]) workqueue.TypedRateLimitingInterface[reconcile.Request] {
+			queue := workqueue.NewTypedRateLimitingQueueWithConfig(rateLimiter, workqueue.TypedRateLimitingQueueConfig[reconcile.Request]{Name: name})
+			return health.WatchQueue(stalls, queue)
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// AnnotationReconcilePaused set to "true" stops the controller from touching the Role and RoleBinding of the namespace
+	AnnotationReconcilePaused = "ns.tagesspiegel.de/reconcile-paused"
+	// AnnotationReconcilePausedUntil optionally ends the pause at the given RFC 3339 point in time
+	AnnotationReconcilePausedUntil = "ns.tagesspiegel.de/reconcile-paused-until"
+
+	// ConditionReconcilePaused reports on the namespace whether the controller skips it
+	ConditionReconcilePaused corev1.NamespaceConditionType = "PermissionReconcilePaused"
+
+	ReasonPaused  = "Paused"
+	ReasonResumed = "Resumed"
+
+	EventReasonInvalidPause = "InvalidPause"
+)
+
+var (
+	ErrInvalidPause = errors.New("invalid pause annotation")
+)
+
+// PauseAnnotations are all annotations that pause the reconciliation of a namespace
+var PauseAnnotations = []string{AnnotationReconcilePaused, AnnotationReconcilePausedUntil}
+
+// Pause is the pause state of a namespace
+type Pause struct {
+	Paused bool
+	// Until is the end of the pause, or the zero time if the pause doesn't expire
+	Until time.Time
+	// Expired is true if the pause ended at Until
+	Expired bool
+}
+
+// PauseOf returns the pause state of the namespace at the given point in time.
+// An invalid annotation or end doesn't pause the namespace and is returned as error.
+func PauseOf(ns *corev1.Namespace, now time.Time) (Pause, error) {
+	value, ok := ns.Annotations[AnnotationReconcilePaused]
+	if !ok {
+		return Pause{}, nil
+	}
+	paused, err := strconv.ParseBool(value)
+	if err != nil {
+		return Pause{}, fmt.Errorf("%w %s=%q: expected true or false", ErrInvalidPause, AnnotationReconcilePaused, value)
+	}
+	if !paused {
+		return Pause{}, nil
+	}
+	value, ok = ns.Annotations[AnnotationReconcilePausedUntil]
+	if !ok {
+		return Pause{Paused: true}, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return Pause{}, fmt.Errorf("%w %s=%q: %w", ErrInvalidPause, AnnotationReconcilePausedUntil, value, err)
+	}
+	if !now.Before(until) {
+		return Pause{Until: until, Expired: true}, nil
+	}
+	return Pause{Paused: true, Until: until}, nil
+}
+
+// TrustedPauseOf is PauseOf, but a pause written by a field manager that isn't trusted doesn't pause the namespace
+// and is returned as error. If trusted is empty, every field manager is accepted.
+func TrustedPauseOf(ns *corev1.Namespace, now time.Time, trusted []string) (Pause, error) {
+	if err := checkFieldManagers(ns, trusted, PauseAnnotations); err != nil {
+		return Pause{}, err
+	}
+	return PauseOf(ns, now)
+}
+
+// condition returns the namespace condition reporting the pause
+func (p Pause) condition(now time.Time) corev1.NamespaceCondition {
+	c := corev1.NamespaceCondition{
+		Type:               ConditionReconcilePaused,
+		Status:             corev1.ConditionFalse,
+		LastTransitionTime: metav1.NewTime(now),
+		Reason:             ReasonResumed,
+		Message:            "permissions are reconciled",
+	}
+	switch {
+	case p.Paused && p.Until.IsZero():
+		c.Status = corev1.ConditionTrue
+		c.Reason = ReasonPaused
+		c.Message = "permissions are not reconciled until " + AnnotationReconcilePaused + " is removed"
+	case p.Paused:
+		c.Status = corev1.ConditionTrue
+		c.Reason = ReasonPaused
+		c.Message = "permissions are not reconciled until " + p.Until.UTC().Format(time.RFC3339)
+	case p.Expired:
+		c.Message = "the pause expired at " + p.Until.UTC().Format(time.RFC3339)
+	}
+	return c
+}
+
+// setPauseCondition sets the pause condition on the namespace and reports whether the status changed.
+// Namespaces that have never been paused don't get the condition.
+func setPauseCondition(ns *corev1.Namespace, p Pause, now time.Time) bool {
+	want := p.condition(now)
+	for i, c := range ns.Status.Conditions {
+		if c.Type != ConditionReconcilePaused {
+			continue
+		}
+		if c.Status == want.Status && c.Reason == want.Reason && c.Message == want.Message {
+			return false
+		}
+		if c.Status == want.Status {
+			want.LastTransitionTime = c.LastTransitionTime
+		}
+		ns.Status.Conditions[i] = want
+		return true
+	}
+	if !p.Paused {
+		return false
+	}
+	ns.Status.Conditions = append(ns.Status.Conditions, want)
+	return true
+}
@@ -0,0 +1,221 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestPauseOf(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        Pause
+		wantErr     error
+	}{
+		{
+			name: "not paused",
+		},
+		{
+			name:        "resumed",
+			annotations: map[string]string{AnnotationReconcilePaused: "false"},
+		},
+		{
+			name:        "paused without expiry",
+			annotations: map[string]string{AnnotationReconcilePaused: "true"},
+			want:        Pause{Paused: true},
+		},
+		{
+			name: "paused until",
+			annotations: map[string]string{
+				AnnotationReconcilePaused:      "true",
+				AnnotationReconcilePausedUntil: "2026-10-01T02:00:00Z",
+			},
+			want: Pause{Paused: true, Until: now.Add(2 * time.Hour)},
+		},
+		{
+			name: "expired pause",
+			annotations: map[string]string{
+				AnnotationReconcilePaused:      "true",
+				AnnotationReconcilePausedUntil: "2026-10-01T00:00:00Z",
+			},
+			want: Pause{Until: now, Expired: true},
+		},
+		{
+			name:        "invalid value doesn't pause",
+			annotations: map[string]string{AnnotationReconcilePaused: "yes"},
+			wantErr:     ErrInvalidPause,
+		},
+		{
+			name: "invalid expiry doesn't pause",
+			annotations: map[string]string{
+				AnnotationReconcilePaused:      "true",
+				AnnotationReconcilePausedUntil: "tomorrow",
+			},
+			wantErr: ErrInvalidPause,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := PauseOf(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Annotations: tt.annotations}}, now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("PauseOf() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("PauseOf() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNamespaceReconciler_Pause(t *testing.T) {
+	live := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team", Labels: managedLabels("team")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.UserKind, Name: "oncall", APIGroup: rbacv1.GroupName}},
+	}
+	namespace := func(annotations map[string]string) *corev1.Namespace {
+		base := map[string]string{
+			AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+			AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=admin",
+		}
+		for k, v := range annotations {
+			base[k] = v
+		}
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:        "team",
+				Labels:      map[string]string{LabelNamespacePermissionControl: "true"},
+				Annotations: base,
+			},
+			Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+		}
+	}
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name          string
+		ns            *corev1.Namespace
+		wantSubject   string
+		trusted       []string
+		wantCondition corev1.ConditionStatus
+		wantRequeue   bool
+	}{
+		{
+			name:          "paused namespace keeps the hand-edited rolebinding",
+			ns:            namespace(map[string]string{AnnotationReconcilePaused: "true"}),
+			wantSubject:   "oncall",
+			wantCondition: corev1.ConditionTrue,
+		},
+		{
+			name: "pause with expiry requeues",
+			ns: namespace(map[string]string{
+				AnnotationReconcilePaused:      "true",
+				AnnotationReconcilePausedUntil: until.Format(time.RFC3339),
+			}),
+			wantSubject:   "oncall",
+			wantCondition: corev1.ConditionTrue,
+			wantRequeue:   true,
+		},
+		{
+			name: "expired pause resumes reconciliation",
+			ns: func() *corev1.Namespace {
+				ns := namespace(map[string]string{
+					AnnotationReconcilePaused:      "true",
+					AnnotationReconcilePausedUntil: "2000-01-01T00:00:00Z",
+				})
+				ns.Status.Conditions = []corev1.NamespaceCondition{{Type: ConditionReconcilePaused, Status: corev1.ConditionTrue, Reason: ReasonPaused}}
+				return ns
+			}(),
+			wantSubject:   "jane",
+			wantCondition: corev1.ConditionFalse,
+		},
+		{
+			name:        "invalid pause doesn't pause",
+			ns:          namespace(map[string]string{AnnotationReconcilePaused: "yes"}),
+			wantSubject: "jane",
+		},
+		{
+			name: "pause of untrusted field manager doesn't pause",
+			ns: func() *corev1.Namespace {
+				ns := namespace(map[string]string{AnnotationReconcilePaused: "true"})
+				ns.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{},"f:ns.tagesspiegel.de/rolebinding-roleref":{}}}}`),
+					managedFieldsEntry("kubectl-annotate", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/reconcile-paused":{}}}}`),
+				}
+				return ns
+			}(),
+			trusted:     []string{"argocd-controller"},
+			wantSubject: "jane",
+		},
+		{
+			name: "pause of trusted field manager pauses",
+			ns: func() *corev1.Namespace {
+				ns := namespace(map[string]string{AnnotationReconcilePaused: "true"})
+				ns.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry("argocd-controller", `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{},"f:ns.tagesspiegel.de/rolebinding-roleref":{},"f:ns.tagesspiegel.de/reconcile-paused":{}}}}`),
+				}
+				return ns
+			}(),
+			trusted:       []string{"argocd-controller"},
+			wantSubject:   "oncall",
+			wantCondition: corev1.ConditionTrue,
+		},
+		{
+			name:        "never paused namespace gets no condition",
+			ns:          namespace(nil),
+			wantSubject: "jane",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+				WithObjects(tt.ns, live.DeepCopy()).
+				WithStatusSubresource(&corev1.Namespace{}).
+				WithInterceptorFuncs(fakeApply(nil, false)).
+				Build()
+			r := &NamespaceReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10), TrustedFieldManagers: tt.trusted}
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}})
+			if err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			if got := result.RequeueAfter > 0; got != tt.wantRequeue {
+				t.Errorf("Reconcile() RequeueAfter = %v, want requeue %v", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			rb := &rbacv1.RoleBinding{}
+			if err := c.Get(context.Background(), client.ObjectKey{Namespace: "team", Name: "team"}, rb); err != nil {
+				t.Fatalf("unable to get rolebinding: %v", err)
+			}
+			if len(rb.Subjects) != 1 || rb.Subjects[0].Name != tt.wantSubject {
+				t.Errorf("RoleBinding subjects = %v, want %q", rb.Subjects, tt.wantSubject)
+			}
+
+			ns := &corev1.Namespace{}
+			if err := c.Get(context.Background(), client.ObjectKey{Name: "team"}, ns); err != nil {
+				t.Fatalf("unable to get namespace: %v", err)
+			}
+			var got corev1.ConditionStatus
+			for _, cond := range ns.Status.Conditions {
+				if cond.Type == ConditionReconcilePaused {
+					got = cond.Status
+				}
+			}
+			if got != tt.wantCondition {
+				t.Errorf("condition %s = %q, want %q", ConditionReconcilePaused, got, tt.wantCondition)
+			}
+		})
+	}
+}
@@ -1,6 +1,13 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
@@ -23,8 +30,15 @@ func (l *LabelChecker) Delete(e event.DeleteEvent) bool {
 	return ok
 }
 
+// Update passes if either version carries the label, so the removal of the label is seen as well
 func (l *LabelChecker) Update(e event.UpdateEvent) bool {
-	_, ok := e.ObjectNew.GetLabels()[l.ExpectedLabel]
+	if _, ok := e.ObjectNew.GetLabels()[l.ExpectedLabel]; ok {
+		return true
+	}
+	if e.ObjectOld == nil {
+		return false
+	}
+	_, ok := e.ObjectOld.GetLabels()[l.ExpectedLabel]
 	return ok
 }
 
@@ -32,3 +46,84 @@ func (l *LabelChecker) Generic(e event.GenericEvent) bool {
 	_, ok := e.Object.GetLabels()[l.ExpectedLabel]
 	return ok
 }
+
+var (
+	_ predicate.Predicate = &ProtectedNamespaceFilter{}
+)
+
+// ProtectedNamespaceFilter filters out all events of namespaces protected by the Guard
+type ProtectedNamespaceFilter struct {
+	Guard *Guard
+}
+
+func (p *ProtectedNamespaceFilter) Create(e event.CreateEvent) bool {
+	return !p.Guard.IsProtectedNamespace(e.Object.GetName())
+}
+
+func (p *ProtectedNamespaceFilter) Delete(e event.DeleteEvent) bool {
+	return !p.Guard.IsProtectedNamespace(e.Object.GetName())
+}
+
+func (p *ProtectedNamespaceFilter) Update(e event.UpdateEvent) bool {
+	return !p.Guard.IsProtectedNamespace(e.ObjectNew.GetName())
+}
+
+func (p *ProtectedNamespaceFilter) Generic(e event.GenericEvent) bool {
+	return !p.Guard.IsProtectedNamespace(e.Object.GetName())
+}
+
+var (
+	_ predicate.Predicate = &NamespaceChangedPredicate{}
+)
+
+// NamespaceChangedPredicate filters out updates of namespaces that don't change anything the controller depends on,
+// e.g. status-only changes or unrelated labels. Periodic resyncs, which don't change the resource version, always pass.
+type NamespaceChangedPredicate struct {
+	predicate.Funcs
+	// TrustedFieldManagers makes changes of the field managers owning the annotations relevant,
+	// as they decide whether the annotations are honoured
+	TrustedFieldManagers []string
+}
+
+func (p *NamespaceChangedPredicate) Update(e event.UpdateEvent) bool {
+	if e.ObjectOld == nil || e.ObjectNew == nil {
+		return true
+	}
+	if e.ObjectOld.GetResourceVersion() == e.ObjectNew.GetResourceVersion() {
+		return true
+	}
+	return ReconcileHash(e.ObjectOld, p.TrustedFieldManagers) != ReconcileHash(e.ObjectNew, p.TrustedFieldManagers)
+}
+
+// reconcileAnnotations are all annotations the reconciliation of a namespace depends on
+var reconcileAnnotations = slices.Concat(PermissionAnnotations, []string{AnnotationReconcilePaused, AnnotationReconcilePausedUntil})
+
+// ReconcileHash returns a hash over the permission and pause annotations, the permission-control label and the phase of a namespace.
+// If trusted field managers are given, the field managers owning the annotations are included as well.
+func ReconcileHash(obj client.Object, trustedFieldManagers []string) string {
+	h := sha256.New()
+	for _, annotation := range reconcileAnnotations {
+		value, ok := obj.GetAnnotations()[annotation]
+		if !ok {
+			continue
+		}
+		h.Write([]byte("annotation:" + annotation + "=" + value + "\n"))
+		if len(trustedFieldManagers) == 0 {
+			continue
+		}
+		managers, err := AnnotationFieldManagers(obj, annotation)
+		if err != nil {
+			// undecodable managed fields are refused, their change is relevant all the same
+			managers = []string{"invalid:" + err.Error()}
+		}
+		slices.Sort(managers)
+		h.Write([]byte("managers:" + annotation + "=" + strings.Join(managers, ",") + "\n"))
+	}
+	if value, ok := obj.GetLabels()[LabelNamespacePermissionControl]; ok {
+		h.Write([]byte("label:" + LabelNamespacePermissionControl + "=" + value + "\n"))
+	}
+	if ns, ok := obj.(*corev1.Namespace); ok {
+		h.Write([]byte("phase:" + string(ns.Status.Phase) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -175,6 +175,28 @@ func TestLabelChecker_Update(t *testing.T) {
 			}},
 			want: false,
 		},
+		{
+			name: "should return true if the label has been removed",
+			fields: fields{
+				ExpectedLabel: LabelNamespacePermissionControl,
+			},
+			args: args{e: event.UpdateEvent{
+				ObjectOld: &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test",
+						Labels: map[string]string{
+							LabelNamespacePermissionControl: "true",
+						},
+					},
+				},
+				ObjectNew: &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test",
+					},
+				},
+			}},
+			want: true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -247,3 +269,177 @@ func TestLabelChecker_Generic(t *testing.T) {
 		})
 	}
 }
+
+func TestProtectedNamespaceFilter_Update(t *testing.T) {
+	type fields struct {
+		Guard *Guard
+	}
+	type args struct {
+		e event.UpdateEvent
+	}
+	tests := []struct {
+		name   string
+		fields fields
+		args   args
+		want   bool
+	}{
+		{
+			name: "should return false if the namespace is protected",
+			fields: fields{
+				Guard: &Guard{ProtectedNamespaces: []string{"kube-*"}},
+			},
+			args: args{e: event.UpdateEvent{
+				ObjectNew: &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "kube-system",
+					},
+				},
+			}},
+			want: false,
+		},
+		{
+			name: "should return true if the namespace is not protected",
+			fields: fields{
+				Guard: &Guard{ProtectedNamespaces: []string{"kube-*"}},
+			},
+			args: args{e: event.UpdateEvent{
+				ObjectNew: &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "test",
+					},
+				},
+			}},
+			want: true,
+		},
+		{
+			name:   "should return true without a guard",
+			fields: fields{},
+			args: args{e: event.UpdateEvent{
+				ObjectNew: &corev1.Namespace{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "kube-system",
+					},
+				},
+			}},
+			want: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &ProtectedNamespaceFilter{
+				Guard: tt.fields.Guard,
+			}
+			if got := p.Update(tt.args.e); got != tt.want {
+				t.Errorf("ProtectedNamespaceFilter.Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceChangedPredicate_Update(t *testing.T) {
+	namespace := func(rv string, mutate func(ns *corev1.Namespace)) *corev1.Namespace {
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "test",
+				ResourceVersion: rv,
+				Labels:          map[string]string{LabelNamespacePermissionControl: "true", "argocd.argoproj.io/instance": "team"},
+				Annotations:     map[string]string{AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane"},
+			},
+			Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+		}
+		if mutate != nil {
+			mutate(ns)
+		}
+		return ns
+	}
+	owned := func(manager string) func(ns *corev1.Namespace) {
+		return func(ns *corev1.Namespace) {
+			ns.ManagedFields = []metav1.ManagedFieldsEntry{
+				managedFieldsEntry(manager, `{"f:metadata":{"f:annotations":{"f:ns.tagesspiegel.de/rolebinding-subjects":{}}}}`),
+			}
+		}
+	}
+	tests := []struct {
+		name    string
+		old     *corev1.Namespace
+		new     *corev1.Namespace
+		trusted []string
+		want    bool
+	}{
+		{
+			name: "periodic resync",
+			old:  namespace("1", nil),
+			new:  namespace("1", nil),
+			want: true,
+		},
+		{
+			name: "unrelated label",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				ns.Labels["argocd.argoproj.io/instance"] = "other"
+			}),
+			want: false,
+		},
+		{
+			name: "status condition",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				ns.Status.Conditions = []corev1.NamespaceCondition{{Type: ConditionReconcilePaused, Status: corev1.ConditionTrue}}
+			}),
+			want: false,
+		},
+		{
+			name: "permission annotation",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				ns.Annotations[AnnotationNamespaceRoleBindingSubjects] = "kind=User;name=john"
+			}),
+			want: true,
+		},
+		{
+			name: "pause annotation",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				ns.Annotations[AnnotationReconcilePaused] = "true"
+			}),
+			want: true,
+		},
+		{
+			name: "control label removed",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				delete(ns.Labels, LabelNamespacePermissionControl)
+			}),
+			want: true,
+		},
+		{
+			name: "phase",
+			old:  namespace("1", nil),
+			new: namespace("2", func(ns *corev1.Namespace) {
+				ns.Status.Phase = corev1.NamespaceTerminating
+			}),
+			want: true,
+		},
+		{
+			name:    "annotation ownership with trusted field managers",
+			old:     namespace("1", owned("kubectl-annotate")),
+			new:     namespace("2", owned("argocd-controller")),
+			trusted: []string{"argocd-controller"},
+			want:    true,
+		},
+		{
+			name: "annotation ownership without trusted field managers",
+			old:  namespace("1", owned("kubectl-annotate")),
+			new:  namespace("2", owned("argocd-controller")),
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &NamespaceChangedPredicate{TrustedFieldManagers: tt.trusted}
+			if got := p.Update(event.UpdateEvent{ObjectOld: tt.old, ObjectNew: tt.new}); got != tt.want {
+				t.Errorf("NamespaceChangedPredicate.Update() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
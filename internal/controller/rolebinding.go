@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"maps"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/tracing"
+)
+
+// managedLabels returns the labels every object created by the controller carries
+func managedLabels(namespace string) map[string]string {
+	return map[string]string{
+		LabelManagedBy:     ManagedByValue,
+		LabelNamespaceName: namespace,
+	}
+}
+
+// RoleBindingSpec describes a RoleBinding managed by the controller
+type RoleBindingSpec struct {
+	Name      string
+	Namespace string
+	RoleRef   rbacv1.RoleRef
+	Subjects  []rbacv1.Subject
+	// Labels are added to the managed labels of the RoleBinding
+	Labels map[string]string
+	// Owner is set as controller reference of the RoleBinding if not nil
+	Owner client.Object
+	// Origin is recorded in the audit log for every change of the RoleBinding
+	Origin audit.Origin
+}
+
+// applyRoleBinding server-side applies the RoleBinding described by the spec and records the change.
+// It returns the subjects bound before the change.
+func applyRoleBinding(ctx context.Context, c client.Client, scheme *runtime.Scheme, changes changeRecorder, spec RoleBindingSpec) ([]rbacv1.Subject, controllerutil.OperationResult, error) {
+	labels := managedLabels(spec.Namespace)
+	maps.Copy(labels, spec.Labels)
+	rb := &rbacv1.RoleBinding{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      spec.Name,
+			Namespace: spec.Namespace,
+			Labels:    labels,
+		},
+		Subjects: spec.Subjects,
+		RoleRef:  spec.RoleRef,
+	}
+	if spec.Owner != nil {
+		if err := ctrl.SetControllerReference(spec.Owner, rb, scheme); err != nil {
+			return nil, controllerutil.OperationResultNone, err
+		}
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "Apply RoleBinding",
+		trace.WithAttributes(AttributeNamespace.String(spec.Namespace), AttributeBinding.String(spec.Name)))
+	defer span.End()
+	fail := func(err error) ([]rbacv1.Subject, controllerutil.OperationResult, error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, controllerutil.OperationResultNone, err
+	}
+
+	before := &rbacv1.RoleBinding{}
+	exists, err := getExisting(ctx, c, client.ObjectKeyFromObject(rb), before)
+	if err != nil {
+		return fail(err)
+	}
+	upToDate := hasLabels(before, labels) &&
+		subjectsEqual(before.Subjects, rb.Subjects) &&
+		before.RoleRef == rb.RoleRef &&
+		(spec.Owner == nil || metav1.IsControlledBy(before, spec.Owner))
+	// the roleRef of a RoleBinding is immutable, a RoleBinding bound to another role is replaced
+	var rslt controllerutil.OperationResult
+	switch {
+	case exists && before.RoleRef != rb.RoleRef && changes.dryRun:
+		// a dry-run apply is rejected as long as the old RoleBinding exists, so the subjects are
+		// defaulted like the API server does for the applied RoleBinding the other branches record
+		rb.Subjects = defaultSubjects(rb.Subjects)
+		rslt = controllerutil.OperationResultUpdated
+	case exists && before.RoleRef != rb.RoleRef:
+		if err := c.Delete(ctx, before); client.IgnoreNotFound(err) != nil {
+			return fail(err)
+		}
+		if _, err = applyObject(ctx, c, "RoleBinding", rb, false, false); err == nil {
+			rslt = controllerutil.OperationResultUpdated
+		}
+	default:
+		rslt, err = applyObject(ctx, c, "RoleBinding", rb, exists, upToDate)
+	}
+	span.SetAttributes(AttributeOperation.String(string(rslt)))
+	if err != nil {
+		return fail(err)
+	}
+	if !exists {
+		before = nil
+	}
+	changes.record(ctx, rslt, "RoleBinding", spec.Namespace, spec.Name, spec.Origin, roleBindingState(before), roleBindingState(rb))
+	var previous []rbacv1.Subject
+	if before != nil {
+		previous = before.Subjects
+	}
+	return previous, rslt, nil
+}
+
+// applyRole server-side applies the Role with the given rules and records the change
+func applyRole(ctx context.Context, c client.Client, changes changeRecorder, role *rbacv1.Role, origin audit.Origin) (controllerutil.OperationResult, error) {
+	role = &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      role.Name,
+			Namespace: role.Namespace,
+			Labels:    role.Labels,
+		},
+		Rules: role.Rules,
+	}
+	ctx, span := tracing.Tracer().Start(ctx, "Apply Role",
+		trace.WithAttributes(AttributeNamespace.String(role.Namespace), AttributeBinding.String(role.Name)))
+	defer span.End()
+
+	fail := func(err error) (controllerutil.OperationResult, error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return controllerutil.OperationResultNone, err
+	}
+
+	before := &rbacv1.Role{}
+	exists, err := getExisting(ctx, c, client.ObjectKeyFromObject(role), before)
+	if err != nil {
+		return fail(err)
+	}
+	upToDate := hasLabels(before, role.Labels) && equality.Semantic.DeepEqual(before.Rules, role.Rules)
+	rslt, err := applyObject(ctx, c, "Role", role, exists, upToDate)
+	span.SetAttributes(AttributeOperation.String(string(rslt)))
+	if err != nil {
+		return fail(err)
+	}
+	if !exists {
+		before = nil
+	}
+	changes.record(ctx, rslt, "Role", role.Namespace, role.Name, origin, roleState(before), roleState(role))
+	return rslt, nil
+}
+
+// deleteRoleBinding deletes the RoleBinding if it exists and records the deletion
+func deleteRoleBinding(ctx context.Context, c client.Client, changes changeRecorder, origin audit.Origin, namespace, name string) error {
+	rb := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, rb); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := c.Delete(ctx, rb); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	changes.record(ctx, OperationDeleted, "RoleBinding", namespace, name, origin, roleBindingState(rb), nil)
+	return nil
+}
+
+// deleteRole deletes the Role if it exists and records the deletion
+func deleteRole(ctx context.Context, c client.Client, changes changeRecorder, origin audit.Origin, namespace, name string) error {
+	role := &rbacv1.Role{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: name}, role); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if err := c.Delete(ctx, role); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	changes.record(ctx, OperationDeleted, "Role", namespace, name, origin, roleState(role), nil)
+	return nil
+}
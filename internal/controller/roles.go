@@ -3,6 +3,8 @@ package controller
 import (
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	rbacv1 "k8s.io/api/rbac/v1"
 
@@ -13,6 +15,7 @@ var (
 	ErrInvalidKeyInRole       = errors.New("invalid key in role")
 	ErrInvalidKeyInRoleRef    = errors.New("invalid key in role ref")
 	ErrInvalidKeyInCustomRole = errors.New("invalid key in custom role")
+	ErrInvalidExpiry          = errors.New("invalid expiry")
 )
 
 const (
@@ -24,9 +27,18 @@ const (
 	KeyAPIGroups     = "apiGroups"
 	KeyResources     = "resources"
 	KeyResourceNames = "resourceNames"
+	KeyExpires       = "expires"
 )
 
-// ParseRoleBindingSubjects parses a string of role binding subjects into a slice of subjects
+// TimedSubject is a role binding subject that optionally loses its access at a given point in time
+type TimedSubject struct {
+	rbacv1.Subject
+	// Expires is the point in time the subject loses its access. A nil value never expires.
+	Expires *time.Time
+}
+
+// ParseRoleBindingSubjects parses a string of role binding subjects into a slice of subjects.
+// Expiry information is dropped, use ParseTimedRoleBindingSubjects to retrieve it.
 //
 // Example:
 //
@@ -36,10 +48,32 @@ const (
 //	}
 //	fmt.Println(rules) // [{Kind:ServiceAccount Name:foo Namespace:bar} {Kind:ServiceAccount Name:foo2 Namespace:bar2}]
 func ParseRoleBindingSubjects(rulesStr string) ([]rbacv1.Subject, error) {
-	subjects := []rbacv1.Subject{}
+	timed, err := ParseTimedRoleBindingSubjects(rulesStr)
+	if err != nil {
+		return nil, err
+	}
+	subjects := make([]rbacv1.Subject, 0, len(timed))
+	for _, t := range timed {
+		subjects = append(subjects, t.Subject)
+	}
+	return subjects, nil
+}
+
+// ParseTimedRoleBindingSubjects parses a string of role binding subjects into a slice of subjects
+// including their optional expiry in RFC 3339 format
+//
+// Example:
+//
+//	rules, err := ParseTimedRoleBindingSubjects("kind=User;name=contractor;expires=2026-11-01T00:00:00Z,kind=Group;name=team-foo")
+//	if err != nil {
+//		// handle error
+//	}
+//	fmt.Println(rules) // [{Subject:{Kind:User Name:contractor} Expires:2026-11-01 00:00:00 +0000 UTC} {Subject:{Kind:Group Name:team-foo} Expires:<nil>}]
+func ParseTimedRoleBindingSubjects(rulesStr string) ([]TimedSubject, error) {
+	subjects := []TimedSubject{}
 	rules := strc.Array(rulesStr)
 	for roleIndex, rule := range rules {
-		subject := rbacv1.Subject{}
+		subject := TimedSubject{}
 		properties := strc.Properties(rule)
 		for keyIndex, item := range properties {
 			key, value, err := strc.KeyValue(item)
@@ -53,6 +87,12 @@ func ParseRoleBindingSubjects(rulesStr string) ([]rbacv1.Subject, error) {
 				subject.Name = value
 			case KeyNamespace:
 				subject.Namespace = value
+			case KeyExpires:
+				expires, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					return nil, fmt.Errorf("%w at index %d in key index %d: %w", ErrInvalidExpiry, roleIndex, keyIndex, err)
+				}
+				subject.Expires = &expires
 			default:
 				return nil, fmt.Errorf("%w at index %d in key index %d with name %q", ErrInvalidKeyInRole, roleIndex, keyIndex, key)
 			}
@@ -133,3 +173,59 @@ func ParseCustomRole(str string) ([]rbacv1.PolicyRule, error) {
 	}
 	return rules, nil
 }
+
+// FormatRoleBindingSubjects formats subjects in the format read by ParseTimedRoleBindingSubjects.
+// The caller has to make sure the values don't contain any of the separators.
+//
+// Example:
+//
+//	expires := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+//	str := FormatRoleBindingSubjects([]TimedSubject{{Subject: rbacv1.Subject{Kind: "User", Name: "contractor"}, Expires: &expires}})
+//	fmt.Println(str) // kind=User;name=contractor;expires=2026-11-01T00:00:00Z
+func FormatRoleBindingSubjects(subjects []TimedSubject) string {
+	items := make([]string, 0, len(subjects))
+	for _, s := range subjects {
+		properties := []string{KeyKind + "=" + s.Kind, KeyName + "=" + s.Name}
+		if s.Namespace != "" {
+			properties = append(properties, KeyNamespace+"="+s.Namespace)
+		}
+		if s.Expires != nil {
+			properties = append(properties, KeyExpires+"="+s.Expires.Format(time.RFC3339))
+		}
+		items = append(items, strings.Join(properties, ";"))
+	}
+	return strings.Join(items, ",")
+}
+
+// FormatRoleBindingRoleRef formats a role ref in the format read by ParseRoleBindingRoleRef
+//
+// Example:
+//
+//	str := FormatRoleBindingRoleRef(rbacv1.RoleRef{APIGroup: "rbac.authorization.k8s.io", Kind: "ClusterRole", Name: "edit"})
+//	fmt.Println(str) // kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit
+func FormatRoleBindingRoleRef(roleRef rbacv1.RoleRef) string {
+	return strings.Join([]string{KeyKind + "=" + roleRef.Kind, KeyAPIGroup + "=" + roleRef.APIGroup, KeyName + "=" + roleRef.Name}, ";")
+}
+
+// FormatCustomRole formats policy rules in the format read by ParseCustomRole.
+// Non-resource URLs can't be expressed and are dropped.
+//
+// Example:
+//
+//	str := FormatCustomRole([]rbacv1.PolicyRule{{Verbs: []string{"get", "watch"}, APIGroups: []string{""}, Resources: []string{"pods"}}})
+//	fmt.Println(str) // verbs=get,watch;apiGroups=;resources=pods
+func FormatCustomRole(rules []rbacv1.PolicyRule) string {
+	items := make([]string, 0, len(rules))
+	for _, r := range rules {
+		properties := []string{
+			KeyVerbs + "=" + strings.Join(r.Verbs, ","),
+			KeyAPIGroups + "=" + strings.Join(r.APIGroups, ","),
+			KeyResources + "=" + strings.Join(r.Resources, ","),
+		}
+		if len(r.ResourceNames) > 0 {
+			properties = append(properties, KeyResourceNames+"="+strings.Join(r.ResourceNames, ","))
+		}
+		items = append(items, strings.Join(properties, ";"))
+	}
+	return strings.Join(items, "::")
+}
@@ -2,6 +2,7 @@ package controller
 
 import (
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	rbacv1 "k8s.io/api/rbac/v1"
@@ -99,6 +100,58 @@ func TestParseRoleBindingSubjects(t *testing.T) {
 	}
 }
 
+func TestParseTimedRoleBindingSubjects(t *testing.T) {
+	expires := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	type args struct {
+		rulesStr string
+	}
+	tests := []struct {
+		name    string
+		args    args
+		want    []TimedSubject
+		wantErr bool
+	}{
+		{
+			name: "with expiry",
+			args: args{
+				rulesStr: "kind=User;name=contractor;expires=2026-11-01T00:00:00Z,kind=Group;name=team-foo",
+			},
+			want: []TimedSubject{
+				{
+					Subject: rbacv1.Subject{Kind: "User", Name: "contractor"},
+					Expires: &expires,
+				},
+				{
+					Subject: rbacv1.Subject{Kind: "Group", Name: "team-foo"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid expiry",
+			args: args{
+				rulesStr: "kind=User;name=contractor;expires=next-week",
+			},
+			want:    nil,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTimedRoleBindingSubjects(tt.args.rulesStr)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseTimedRoleBindingSubjects() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+
+			diff := cmp.Diff(got, tt.want)
+			if diff != "" {
+				t.Errorf("ParseTimedRoleBindingSubjects() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
 func TestParseRoleBindingRoleRef(t *testing.T) {
 	type args struct {
 		str string
@@ -270,3 +323,39 @@ func TestParseCustomRole(t *testing.T) {
 		})
 	}
 }
+
+func TestFormatRoundTrip(t *testing.T) {
+	expires := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	subjects := []TimedSubject{
+		{Subject: rbacv1.Subject{Kind: "ServiceAccount", Name: "deployer", Namespace: "ci"}},
+		{Subject: rbacv1.Subject{Kind: "User", Name: "contractor"}, Expires: &expires},
+	}
+	gotSubjects, err := ParseTimedRoleBindingSubjects(FormatRoleBindingSubjects(subjects))
+	if err != nil {
+		t.Fatalf("ParseTimedRoleBindingSubjects() error = %v", err)
+	}
+	if diff := cmp.Diff(subjects, gotSubjects); diff != "" {
+		t.Errorf("subjects round trip mismatch (-want +got):\n%s", diff)
+	}
+
+	roleRef := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"}
+	gotRoleRef, err := ParseRoleBindingRoleRef(FormatRoleBindingRoleRef(roleRef))
+	if err != nil {
+		t.Fatalf("ParseRoleBindingRoleRef() error = %v", err)
+	}
+	if gotRoleRef != roleRef {
+		t.Errorf("role ref round trip = %v, want %v", gotRoleRef, roleRef)
+	}
+
+	rules := []rbacv1.PolicyRule{
+		{Verbs: []string{"get", "list"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"configmaps"}, ResourceNames: []string{"settings"}},
+	}
+	gotRules, err := ParseCustomRole(FormatCustomRole(rules))
+	if err != nil {
+		t.Fatalf("ParseCustomRole() error = %v", err)
+	}
+	if diff := cmp.Diff(rules, gotRules); diff != "" {
+		t.Errorf("rules round trip mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Shard decides which namespaces a replica reconciles
+type Shard interface {
+	// Owns reports whether the replica reconciles the namespace
+	Owns(namespace string) bool
+	// Changed is signalled whenever the owned namespaces change. It may be nil if they never change.
+	Changed() <-chan struct{}
+}
+
+var _ manager.LeaderElectionRunnable = &rebalancer{}
+
+// rebalancer enqueues all owned namespaces whenever the shard changes, so namespaces taken over
+// from other replicas are reconciled right away
+type rebalancer struct {
+	reader client.Reader
+	shard  Shard
+	events chan<- event.GenericEvent
+}
+
+func (r *rebalancer) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *rebalancer) Start(ctx context.Context) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.shard.Changed():
+			if err := r.enqueue(ctx); err != nil {
+				logf.Log.WithName("shard").Error(err, "unable to rebalance namespaces")
+			}
+		}
+	}
+}
+
+func (r *rebalancer) enqueue(ctx context.Context) error {
+	list := &corev1.NamespaceList{}
+	if err := r.reader.List(ctx, list, client.HasLabels{LabelNamespacePermissionControl}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if !r.shard.Owns(list.Items[i].Name) {
+			continue
+		}
+		select {
+		case r.events <- event.GenericEvent{Object: &list.Items[i]}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
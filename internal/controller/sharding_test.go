@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fixedShard owns the given namespaces
+type fixedShard map[string]bool
+
+func (s fixedShard) Owns(namespace string) bool { return s[namespace] }
+func (s fixedShard) Changed() <-chan struct{}   { return nil }
+
+func TestNamespaceReconciler_Shard(t *testing.T) {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	tests := []struct {
+		name        string
+		shard       Shard
+		wantBinding bool
+	}{
+		{name: "without sharding", wantBinding: true},
+		{name: "owned namespace", shard: fixedShard{"team": true}, wantBinding: true},
+		{name: "namespace of another shard", shard: fixedShard{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns.DeepCopy()).
+				WithInterceptorFuncs(fakeApply(nil, false)).Build()
+			r := &NamespaceReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10), Shard: tt.shard}
+			ctx := context.Background()
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: "team"}}); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+			err := c.Get(ctx, client.ObjectKey{Namespace: "team", Name: "team"}, &rbacv1.RoleBinding{})
+			if tt.wantBinding == apierrors.IsNotFound(err) {
+				t.Errorf("rolebinding exists = %v, want %v", err == nil, tt.wantBinding)
+			}
+		})
+	}
+}
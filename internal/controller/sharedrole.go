@@ -0,0 +1,243 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/audit"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/tracing"
+)
+
+const (
+	// SharedRolePrefix is the name prefix of the ClusterRoles shared by all namespaces with the same custom rules
+	SharedRolePrefix = "nspm-rules-"
+	// LabelSharedRules carries the hash of the rules on every shared ClusterRole
+	LabelSharedRules = "ns.tagesspiegel.de/shared-rules"
+)
+
+// NormalizeRules returns a copy of the rules in a canonical form. The values of every rule are sorted and
+// deduplicated, and so are the rules themselves, so rule sets that only differ in order normalize to the same rules.
+func NormalizeRules(rules []rbacv1.PolicyRule) []rbacv1.PolicyRule {
+	normalized := make([]rbacv1.PolicyRule, 0, len(rules))
+	for _, r := range rules {
+		normalized = append(normalized, rbacv1.PolicyRule{
+			Verbs:           sortedSet(r.Verbs),
+			APIGroups:       sortedSet(r.APIGroups),
+			Resources:       sortedSet(r.Resources),
+			ResourceNames:   sortedSet(r.ResourceNames),
+			NonResourceURLs: sortedSet(r.NonResourceURLs),
+		})
+	}
+	slices.SortFunc(normalized, func(a, b rbacv1.PolicyRule) int {
+		return strings.Compare(ruleKey(a), ruleKey(b))
+	})
+	return slices.CompactFunc(normalized, func(a, b rbacv1.PolicyRule) bool {
+		return equality.Semantic.DeepEqual(a, b)
+	})
+}
+
+func sortedSet(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	return slices.Compact(sorted)
+}
+
+// ruleKey orders normalized rules
+func ruleKey(rule rbacv1.PolicyRule) string {
+	// a PolicyRule only holds strings, marshalling can't fail
+	data, _ := json.Marshal(rule)
+	return string(data)
+}
+
+// rulesHash returns a short hash over the normalized rules
+func rulesHash(rules []rbacv1.PolicyRule) string {
+	data, _ := json.Marshal(NormalizeRules(rules))
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+// SharedRoleName returns the name of the ClusterRole shared by all namespaces with the given custom rules
+func SharedRoleName(rules []rbacv1.PolicyRule) string {
+	return SharedRolePrefix + rulesHash(rules)
+}
+
+// SharedRoleRef returns the reference to the ClusterRole shared by all namespaces with the given custom rules
+func SharedRoleRef(rules []rbacv1.PolicyRule) rbacv1.RoleRef {
+	return rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: SharedRoleName(rules)}
+}
+
+// IsSharedRoleRef reports whether the role ref points to a shared ClusterRole
+func IsSharedRoleRef(ref rbacv1.RoleRef) bool {
+	return ref.APIGroup == rbacv1.GroupName && ref.Kind == "ClusterRole" && strings.HasPrefix(ref.Name, SharedRolePrefix)
+}
+
+// ShareRole replaces the custom Role of the desired state with the ClusterRole shared by all namespaces
+// with the same custom rules and binds the subjects to it. Namespaces without custom rules are left as they are.
+func (d *DesiredState) ShareRole() {
+	if d.Role == nil {
+		return
+	}
+	hash := rulesHash(d.Rules)
+	d.Role = nil
+	d.RoleRef = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: SharedRolePrefix + hash}
+	if d.RoleBinding == nil {
+		// nothing would be bound to the ClusterRole, so it would be collected right away
+		return
+	}
+	d.RoleBinding.RoleRef = d.RoleRef
+	d.ClusterRole = &rbacv1.ClusterRole{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name: d.RoleRef.Name,
+			Labels: map[string]string{
+				LabelManagedBy:   ManagedByValue,
+				LabelSharedRules: hash,
+			},
+		},
+		Rules: NormalizeRules(d.Rules),
+	}
+}
+
+// applyClusterRole server-side applies the shared ClusterRole and records the change
+func applyClusterRole(ctx context.Context, c client.Client, changes changeRecorder, cr *rbacv1.ClusterRole, origin audit.Origin) (controllerutil.OperationResult, error) {
+	ctx, span := tracing.Tracer().Start(ctx, "Apply ClusterRole", trace.WithAttributes(AttributeBinding.String(cr.Name)))
+	defer span.End()
+
+	fail := func(err error) (controllerutil.OperationResult, error) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return controllerutil.OperationResultNone, err
+	}
+
+	before := &rbacv1.ClusterRole{}
+	exists, err := getExisting(ctx, c, client.ObjectKeyFromObject(cr), before)
+	if err != nil {
+		return fail(err)
+	}
+	upToDate := hasLabels(before, cr.Labels) && equality.Semantic.DeepEqual(before.Rules, cr.Rules)
+	rslt, err := applyObject(ctx, c, "ClusterRole", cr, exists, upToDate)
+	span.SetAttributes(AttributeOperation.String(string(rslt)))
+	if err != nil {
+		return fail(err)
+	}
+	if !exists {
+		before = nil
+	}
+	changes.record(ctx, rslt, "ClusterRole", "", cr.Name, origin, clusterRoleState(before), clusterRoleState(cr))
+	return rslt, nil
+}
+
+// sharedRoleUsers maps a shared ClusterRole to the namespaces bound to it,
+// so a shared ClusterRole that is changed or deleted while in use is restored
+func (r *NamespaceReconciler) sharedRoleUsers(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &rbacv1.RoleBindingList{}
+	if err := r.List(ctx, list, client.MatchingLabels{LabelManagedBy: ManagedByValue}); err != nil {
+		logf.FromContext(ctx).Error(err, "unable to list rolebindings bound to shared clusterrole", "name", obj.GetName())
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, rb := range list.Items {
+		namespace := rb.Labels[LabelNamespaceName]
+		if namespace == "" || !IsSharedRoleRef(rb.RoleRef) || rb.RoleRef.Name != obj.GetName() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Name: namespace}})
+	}
+	return requests
+}
+
+var _ manager.LeaderElectionRunnable = &SharedRoleCollector{}
+
+// SharedRoleCollector periodically deletes the shared ClusterRoles no managed RoleBinding is bound to anymore,
+// e.g. because the custom rules of all namespaces sharing them changed. It only runs on the leader,
+// as the references are counted across all namespaces.
+type SharedRoleCollector struct {
+	// Client lists the managed RoleBindings and shared ClusterRoles and deletes the unused ones
+	Client client.Client
+	// Interval between two collections
+	Interval time.Duration
+	// MinAge spares ClusterRoles created recently, as the RoleBinding bound to them may not be applied yet
+	MinAge time.Duration
+	// Audit records every deleted ClusterRole. A nil Audit records nothing.
+	Audit *audit.Logger
+	// DryRun only plans the deletions. The Client is expected to be a dry-run client.
+	DryRun bool
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (g *SharedRoleCollector) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable. It collects right away and then once per interval.
+// Failures are logged and retried with the next collection.
+func (g *SharedRoleCollector) Start(ctx context.Context) error {
+	ticker := time.NewTicker(g.Interval)
+	defer ticker.Stop()
+	for {
+		if err := g.Collect(ctx, time.Now()); err != nil {
+			logf.Log.WithName("shared-roles").Error(err, "unable to collect unused shared clusterroles")
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect counts the RoleBindings bound to every shared ClusterRole and deletes the ones without any,
+// unless they were created less than MinAge ago
+func (g *SharedRoleCollector) Collect(ctx context.Context, now time.Time) error {
+	roles := &rbacv1.ClusterRoleList{}
+	if err := g.Client.List(ctx, roles, client.MatchingLabels{LabelManagedBy: ManagedByValue}, client.HasLabels{LabelSharedRules}); err != nil {
+		return err
+	}
+	bindings := &rbacv1.RoleBindingList{}
+	if err := g.Client.List(ctx, bindings, client.MatchingLabels{LabelManagedBy: ManagedByValue}); err != nil {
+		return err
+	}
+	refs := map[string]int{}
+	for _, rb := range bindings.Items {
+		if IsSharedRoleRef(rb.RoleRef) {
+			refs[rb.RoleRef.Name]++
+		}
+	}
+
+	changes := changeRecorder{audit: g.Audit, dryRun: g.DryRun}
+	remaining := 0
+	for i := range roles.Items {
+		cr := &roles.Items[i]
+		if refs[cr.Name] > 0 || now.Sub(cr.CreationTimestamp.Time) < g.MinAge {
+			remaining++
+			continue
+		}
+		if err := g.Client.Delete(ctx, cr); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		logf.Log.WithName("shared-roles").Info("deleted unused shared clusterrole", "name", cr.Name)
+		// nothing is bound to the ClusterRole anymore, it is the only origin left
+		changes.record(ctx, OperationDeleted, "ClusterRole", "", cr.Name, audit.Origin{Kind: "ClusterRole", Name: cr.Name}, clusterRoleState(cr), nil)
+	}
+	sharedClusterRoles.Set(float64(remaining))
+	return nil
+}
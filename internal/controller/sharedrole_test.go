@@ -0,0 +1,248 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestSharedRoleName(t *testing.T) {
+	readPods := []rbacv1.PolicyRule{
+		{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+		{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+	}
+	tests := []struct {
+		name  string
+		rules []rbacv1.PolicyRule
+		same  bool
+	}{
+		{
+			name: "reordered rules and values",
+			rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+				{Verbs: []string{"list", "get", "get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+			},
+			same: true,
+		},
+		{
+			name:  "duplicate rule",
+			rules: append([]rbacv1.PolicyRule{readPods[1]}, readPods...),
+			same:  true,
+		},
+		{
+			name: "other verbs",
+			rules: []rbacv1.PolicyRule{
+				{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}},
+				readPods[1],
+			},
+		},
+		{
+			name:  "subset",
+			rules: readPods[:1],
+		},
+	}
+	want := SharedRoleName(readPods)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SharedRoleName(tt.rules); (got == want) != tt.same {
+				t.Errorf("SharedRoleName() = %q, same as %q = %v, want %v", got, want, got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestDesiredState_ShareRole(t *testing.T) {
+	ns := func(annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team", Annotations: annotations}}
+	}
+	rules := []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	shared := SharedRoleRef(rules)
+
+	tests := []struct {
+		name            string
+		ns              *corev1.Namespace
+		wantClusterRole bool
+		wantRoleRef     rbacv1.RoleRef
+	}{
+		{
+			name: "binds subjects to shared clusterrole",
+			ns: ns(map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+				AnnotationNamespaceCustomRoleRules:     "verbs=list,get;apiGroups=;resources=pods",
+			}),
+			wantClusterRole: true,
+			wantRoleRef:     shared,
+		},
+		{
+			name: "no clusterrole without subjects",
+			ns: ns(map[string]string{
+				AnnotationNamespaceCustomRoleRules: "verbs=list,get;apiGroups=;resources=pods",
+			}),
+			wantRoleRef: shared,
+		},
+		{
+			name: "keeps role ref without custom rules",
+			ns: ns(map[string]string{
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=view",
+			}),
+			wantRoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			desired, err := Desired(tt.ns, time.Now())
+			if err != nil {
+				t.Fatalf("Desired() error = %v", err)
+			}
+			desired.ShareRole()
+			if desired.Role != nil {
+				t.Errorf("Role = %v, want nil", desired.Role)
+			}
+			if diff := cmp.Diff(tt.wantRoleRef, desired.RoleRef); diff != "" {
+				t.Errorf("RoleRef mismatch (-want +got):\n%s", diff)
+			}
+			if desired.RoleBinding != nil && desired.RoleBinding.RoleRef != tt.wantRoleRef {
+				t.Errorf("RoleBinding.RoleRef = %v, want %v", desired.RoleBinding.RoleRef, tt.wantRoleRef)
+			}
+			if (desired.ClusterRole != nil) != tt.wantClusterRole {
+				t.Fatalf("ClusterRole = %v, want %v", desired.ClusterRole, tt.wantClusterRole)
+			}
+			if desired.ClusterRole != nil {
+				if desired.ClusterRole.Name != shared.Name {
+					t.Errorf("ClusterRole.Name = %q, want %q", desired.ClusterRole.Name, shared.Name)
+				}
+				if diff := cmp.Diff(rules, desired.ClusterRole.Rules); diff != "" {
+					t.Errorf("ClusterRole.Rules mismatch (-want +got):\n%s", diff)
+				}
+			}
+		})
+	}
+}
+
+func TestNamespaceReconciler_SharedRoles(t *testing.T) {
+	namespace := func(name, rules string) *corev1.Namespace {
+		return &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=" + name,
+					AnnotationNamespaceCustomRoleRules:     rules,
+				},
+			},
+			Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+		}
+	}
+	// created before the rules were shared
+	role := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "a", Labels: managedLabels("a")}}
+	binding := &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "a", Labels: managedLabels("a")},
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "a"},
+		Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "a"}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).
+		WithObjects(
+			namespace("a", "verbs=get,list;apiGroups=;resources=pods"),
+			namespace("b", "verbs=list,get;apiGroups=;resources=pods"),
+			namespace("c", "verbs=get;apiGroups=;resources=pods"),
+			role, binding,
+		).
+		WithInterceptorFuncs(fakeApply(nil, false)).
+		Build()
+	r := &NamespaceReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10), SharedRoles: true}
+
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c"} {
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKey{Name: name}}); err != nil {
+			t.Fatalf("Reconcile(%s) error = %v", name, err)
+		}
+	}
+
+	roleRefs := map[string]string{}
+	for _, name := range []string{"a", "b", "c"} {
+		rb := &rbacv1.RoleBinding{}
+		if err := c.Get(ctx, client.ObjectKey{Namespace: name, Name: name}, rb); err != nil {
+			t.Fatalf("Get(RoleBinding %s) error = %v", name, err)
+		}
+		roleRefs[name] = rb.RoleRef.Kind + "/" + rb.RoleRef.Name
+	}
+	readPods := SharedRoleName([]rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}})
+	getPods := SharedRoleName([]rbacv1.PolicyRule{{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}})
+	want := map[string]string{"a": "ClusterRole/" + readPods, "b": "ClusterRole/" + readPods, "c": "ClusterRole/" + getPods}
+	if diff := cmp.Diff(want, roleRefs); diff != "" {
+		t.Errorf("role refs mismatch (-want +got):\n%s", diff)
+	}
+
+	clusterRoles := &rbacv1.ClusterRoleList{}
+	if err := c.List(ctx, clusterRoles, client.HasLabels{LabelSharedRules}); err != nil {
+		t.Fatal(err)
+	}
+	if len(clusterRoles.Items) != 2 {
+		t.Errorf("shared clusterroles = %d, want 2", len(clusterRoles.Items))
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(role), &rbacv1.Role{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Get(Role a) error = %v, want the role replaced by the shared clusterrole to be removed", err)
+	}
+
+	got := r.sharedRoleUsers(ctx, &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: readPods}})
+	wantUsers := []reconcile.Request{{NamespacedName: client.ObjectKey{Name: "a"}}, {NamespacedName: client.ObjectKey{Name: "b"}}}
+	if diff := cmp.Diff(wantUsers, got); diff != "" {
+		t.Errorf("sharedRoleUsers() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestSharedRoleCollector(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	clusterRole := func(name string, age time.Duration) *rbacv1.ClusterRole {
+		return &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{
+			Name:              SharedRolePrefix + name,
+			Labels:            map[string]string{LabelManagedBy: ManagedByValue, LabelSharedRules: name},
+			CreationTimestamp: metav1.NewTime(now.Add(-age)),
+		}}
+	}
+	binding := func(namespace, clusterRole string) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace, Namespace: namespace, Labels: managedLabels(namespace)},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: SharedRolePrefix + clusterRole},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		clusterRole("used", time.Hour),
+		clusterRole("unused", time.Hour),
+		clusterRole("new", time.Second),
+		// not shared, never collected
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit", CreationTimestamp: metav1.NewTime(now.Add(-time.Hour))}},
+		binding("a", "used"),
+		binding("b", "used"),
+	).Build()
+
+	g := &SharedRoleCollector{Client: c, MinAge: time.Minute}
+	if err := g.Collect(context.Background(), now); err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	list := &rbacv1.ClusterRoleList{}
+	if err := c.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cr := range list.Items {
+		got = append(got, cr.Name)
+	}
+	want := []string{"edit", SharedRolePrefix + "new", SharedRolePrefix + "used"}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("clusterroles after Collect() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,37 @@
+package controller
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// attribute keys recorded on the spans of the controller
+const (
+	AttributeNamespace = attribute.Key("k8s.namespace.name")
+	AttributeBinding   = attribute.Key("nspm.binding")
+	AttributeOutcome   = attribute.Key("nspm.outcome")
+	AttributeOperation = attribute.Key("nspm.operation")
+)
+
+// outcomes of a reconciliation recorded on its span
+const (
+	OutcomeIgnored       = "ignored"
+	OutcomeProtected     = "protected"
+	OutcomePaused        = "paused"
+	OutcomeOtherShard    = "other-shard"
+	OutcomeReadError     = "read-error"
+	OutcomeUntrusted     = "untrusted-field-manager"
+	OutcomeParseError    = "parse-error"
+	OutcomeSubjectDenied = "subject-denied"
+	OutcomePolicyDenied  = "policy-denied"
+	OutcomeApplyError    = "apply-error"
+	OutcomeApplied       = "applied"
+	OutcomeCleanedUp     = "cleaned-up"
+)
+
+// setOutcome records the outcome of the reconciliation on the span of the context
+func setOutcome(ctx context.Context, outcome string) {
+	trace.SpanFromContext(ctx).SetAttributes(AttributeOutcome.String(outcome))
+}
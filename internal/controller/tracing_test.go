@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestNamespaceReconciler_Spans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				AnnotationNamespaceCustomRoleRules:     "verbs=get;apiGroups=;resources=pods",
+				AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team;apiGroup=rbac.authorization.k8s.io;until=invalid",
+			},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).Build()
+	r := &NamespaceReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ns)}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	var root sdktrace.ReadOnlySpan
+	children := map[string]codes.Code{}
+	for _, span := range recorder.Ended() {
+		if span.Name() == "NamespaceReconciler.Reconcile" {
+			root = span
+		}
+	}
+	if root == nil {
+		t.Fatal("no NamespaceReconciler.Reconcile span recorded")
+	}
+	for _, span := range recorder.Ended() {
+		if span.Parent().SpanID() == root.SpanContext().SpanID() {
+			children[span.Name()] = span.Status().Code
+		}
+	}
+	want := map[string]codes.Code{
+		"ParseRoleBindingRoleRef":       codes.Unset,
+		"ParseCustomRole":               codes.Unset,
+		"ParseTimedRoleBindingSubjects": codes.Error,
+	}
+	if diff := cmp.Diff(want, children); diff != "" {
+		t.Errorf("child spans of reconciliation mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestNamespaceReconciler_OutcomeOnError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "team",
+			Labels: map[string]string{LabelNamespacePermissionControl: "true"},
+		},
+		Status: corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	// marking the namespace as managed fails
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(ns).WithInterceptorFuncs(interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return errors.New("patch failed")
+		},
+	}).Build()
+	r := &NamespaceReconciler{Client: c, Scheme: scheme.Scheme, Recorder: record.NewFakeRecorder(10)}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ns)}); err == nil {
+		t.Fatal("Reconcile() expected an error")
+	}
+
+	var outcome string
+	for _, span := range recorder.Ended() {
+		if span.Name() != "NamespaceReconciler.Reconcile" {
+			continue
+		}
+		for _, attr := range span.Attributes() {
+			if attr.Key == AttributeOutcome {
+				outcome = attr.Value.AsString()
+			}
+		}
+	}
+	if outcome != OutcomeApplyError {
+		t.Errorf("outcome = %q, want %q", outcome, OutcomeApplyError)
+	}
+}
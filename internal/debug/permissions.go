@@ -0,0 +1,54 @@
+// Package debug serves read-only diagnostics of the controller.
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/inventory"
+)
+
+// PermissionsPath is the path the PermissionsHandler is served on
+const PermissionsPath = "/debug/permissions"
+
+var debuglog = logf.Log.WithName("debug")
+
+// PermissionsHandler serves the desired and actual permissions of every managed namespace as JSON.
+// A single namespace can be selected with the namespace query parameter.
+type PermissionsHandler struct {
+	// Reader is used to read namespaces, Roles and RoleBindings. It should read from the API server directly,
+	// so the controller doesn't need to cache all Roles and RoleBindings of the cluster.
+	Reader client.Reader
+	// Guard excludes protected namespaces. A nil Guard protects nothing.
+	Guard *controller.Guard
+}
+
+// ServeHTTP implements http.Handler
+func (h *PermissionsHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), 30*time.Second)
+	defer cancel()
+
+	result, err := inventory.Collect(ctx, h.Reader, h.Guard, req.URL.Query().Get("namespace"), time.Now())
+	if err != nil {
+		debuglog.Error(err, "unable to collect permissions")
+		http.Error(w, "unable to collect permissions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(result); err != nil {
+		debuglog.Error(err, "unable to write response")
+	}
+}
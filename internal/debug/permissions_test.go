@@ -0,0 +1,92 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/inventory"
+)
+
+func TestPermissionsHandler(t *testing.T) {
+	managedNamespace := func(name, subjects string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+			Annotations: map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: subjects,
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;name=edit;apiGroup=rbac.authorization.k8s.io",
+			},
+		}}
+	}
+	rolebinding := func(name string, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+			Subjects:   subjects,
+		}
+	}
+	team := rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "team"}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		managedNamespace("in-sync", "kind=Group;name=team"),
+		rolebinding("in-sync", team),
+		managedNamespace("drifted", "kind=Group;name=team,kind=User;name=jane"),
+		rolebinding("drifted", team),
+		managedNamespace("missing", "kind=Group;name=team"),
+		managedNamespace("broken", "kind=Group;foo=bar"),
+		managedNamespace("kube-system", "kind=Group;name=team"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+	).Build()
+	h := &PermissionsHandler{Reader: c, Guard: &controller.Guard{ProtectedNamespaces: []string{"kube-*"}}}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PermissionsPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PermissionsHandler status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	got := []inventory.NamespacePermissions{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	differences := map[string][]string{}
+	for _, p := range got {
+		if p.InSync != (len(p.Differences) == 0) {
+			t.Errorf("namespace %q inSync = %v with differences %v", p.Namespace, p.InSync, p.Differences)
+		}
+		differences[p.Namespace] = p.Differences
+	}
+	want := map[string][]string{
+		"in-sync": nil,
+		"drifted": {"rolebinding subjects differ"},
+		"missing": {"rolebinding is missing"},
+		"broken":  {"annotation ns.tagesspiegel.de/rolebinding-subjects can't be parsed"},
+	}
+	if diff := cmp.Diff(want, differences); diff != "" {
+		t.Errorf("PermissionsHandler differences mismatch (-want +got):\n%s", diff)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, PermissionsPath+"?namespace=drifted", nil))
+	got = []inventory.NamespacePermissions{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Namespace != "drifted" {
+		t.Errorf("PermissionsHandler with namespace filter returned %v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, PermissionsPath, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PermissionsHandler POST status = %d, want %d", rec.Code, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,239 @@
+// Package health implements the readiness and liveness checks of the manager.
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+)
+
+var (
+	ErrCacheNotSynced     = errors.New("informer caches are not synced")
+	ErrMissingPermissions = errors.New("controller is missing permissions")
+	ErrStalled            = errors.New("reconciliation stalled")
+)
+
+// checkTimeout bounds every request made by a check, so probes don't hang
+const checkTimeout = 5 * time.Second
+
+// Syncer waits for informer caches to sync, e.g. the cache of the manager
+type Syncer interface {
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// CacheSynced fails until the informer caches are synced
+func CacheSynced(cache Syncer) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), checkTimeout)
+		defer cancel()
+		if !cache.WaitForCacheSync(ctx) {
+			return ErrCacheNotSynced
+		}
+		return nil
+	}
+}
+
+// RBACReachable fails if RoleBindings can't be listed from the API server
+func RBACReachable(reader client.Reader) healthz.Checker {
+	return func(req *http.Request) error {
+		ctx, cancel := context.WithTimeout(req.Context(), checkTimeout)
+		defer cancel()
+		if err := reader.List(ctx, &rbacv1.RoleBindingList{}, client.Limit(1)); err != nil {
+			return fmt.Errorf("unable to reach the RBAC API: %w", err)
+		}
+		return nil
+	}
+}
+
+// RequiredPermissions are the permissions the controller needs to manage Roles and RoleBindings.
+// They are written with server-side apply, which needs patch, and the namespaces are marked as managed
+// and get the pause condition in their status.
+var RequiredPermissions = []authorizationv1.ResourceAttributes{
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "create"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "update"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "patch"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "delete"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "bind"},
+	{Group: rbacv1.GroupName, Resource: "roles", Verb: "escalate"},
+	{Group: rbacv1.GroupName, Resource: "rolebindings", Verb: "create"},
+	{Group: rbacv1.GroupName, Resource: "rolebindings", Verb: "update"},
+	{Group: rbacv1.GroupName, Resource: "rolebindings", Verb: "patch"},
+	{Group: rbacv1.GroupName, Resource: "rolebindings", Verb: "delete"},
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "bind"},
+	{Group: "", Resource: "namespaces", Verb: "patch"},
+	{Group: "", Resource: "namespaces", Subresource: "status", Verb: "update"},
+}
+
+// SharedRolePermissions are the additional permissions the controller needs to manage shared ClusterRoles
+var SharedRolePermissions = []authorizationv1.ResourceAttributes{
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "create"},
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "update"},
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "patch"},
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "delete"},
+	{Group: rbacv1.GroupName, Resource: "clusterroles", Verb: "escalate"},
+}
+
+// SelfCheck verifies with SelfSubjectAccessReviews that the controller holds the RequiredPermissions.
+// Once the check succeeded, its result is cached and the API server isn't asked again.
+type SelfCheck struct {
+	Client client.Client
+	// SharedRoles additionally requires the SharedRolePermissions
+	SharedRoles bool
+
+	mu     sync.Mutex
+	passed bool
+}
+
+// Check implements healthz.Checker
+func (s *SelfCheck) Check(req *http.Request) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.passed {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), checkTimeout)
+	defer cancel()
+	required := RequiredPermissions
+	if s.SharedRoles {
+		required = append(slices.Clone(required), SharedRolePermissions...)
+	}
+	var missing []string
+	for _, attrs := range required {
+		review := &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: attrs.DeepCopy()},
+		}
+		if err := s.Client.Create(ctx, review); err != nil {
+			return fmt.Errorf("unable to review permissions: %w", err)
+		}
+		if !review.Status.Allowed {
+			resource := attrs.Resource
+			if attrs.Subresource != "" {
+				resource += "/" + attrs.Subresource
+			}
+			missing = append(missing, attrs.Verb+" "+resource)
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingPermissions, strings.Join(missing, ", "))
+	}
+	s.passed = true
+	return nil
+}
+
+// StallDetector tracks running reconciliations and fails the liveness check
+// if one of them runs longer than the threshold. It also fails if requests of a watched work queue
+// wait longer than the threshold without any request being done, e.g. because its workers are gone.
+// A nil StallDetector tracks nothing.
+type StallDetector struct {
+	// Threshold is the maximum duration of a reconciliation. Zero disables the check.
+	Threshold time.Duration
+
+	mu      sync.Mutex
+	next    uint64
+	running map[uint64]time.Time
+	queues  []*queueState
+	now     func() time.Time
+}
+
+// queueState is the progress of a watched work queue
+type queueState struct {
+	len func() int
+	// lastDone is the time the last request was done, or the queue was created
+	lastDone time.Time
+	// queuedSince is the time the check first saw requests waiting, or zero if none wait
+	queuedSince time.Time
+}
+
+// watchedQueue records the requests done by the work queue it wraps
+type watchedQueue[T comparable] struct {
+	workqueue.TypedRateLimitingInterface[T]
+	detector *StallDetector
+	state    *queueState
+}
+
+// Done implements workqueue.TypedInterface
+func (q *watchedQueue[T]) Done(item T) {
+	q.TypedRateLimitingInterface.Done(item)
+	q.detector.mu.Lock()
+	defer q.detector.mu.Unlock()
+	q.state.lastDone = q.detector.now()
+}
+
+// WatchQueue wraps the work queue of a controller, so the StallDetector notices requests that are queued
+// but not processed. A nil StallDetector returns the queue as it is.
+func WatchQueue[T comparable](d *StallDetector, queue workqueue.TypedRateLimitingInterface[T]) workqueue.TypedRateLimitingInterface[T] {
+	if d == nil {
+		return queue
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	state := &queueState{len: queue.Len, lastDone: d.now()}
+	d.queues = append(d.queues, state)
+	return &watchedQueue[T]{TypedRateLimitingInterface: queue, detector: d, state: state}
+}
+
+// NewStallDetector returns a StallDetector failing for reconciliations running longer than threshold
+func NewStallDetector(threshold time.Duration) *StallDetector {
+	return &StallDetector{Threshold: threshold, running: map[uint64]time.Time{}, now: time.Now}
+}
+
+// Begin records the start of a reconciliation. The returned function has to be called once it is done.
+func (d *StallDetector) Begin() func() {
+	if d == nil {
+		return func() {}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := d.next
+	d.next++
+	d.running[id] = d.now()
+	return func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		delete(d.running, id)
+	}
+}
+
+// Check implements healthz.Checker
+func (d *StallDetector) Check(_ *http.Request) error {
+	if d == nil || d.Threshold <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := d.now()
+	for _, started := range d.running {
+		if running := now.Sub(started); running > d.Threshold {
+			return fmt.Errorf("%w: a reconciliation is running for %s", ErrStalled, running.Round(time.Second))
+		}
+	}
+	for _, q := range d.queues {
+		queued := q.len()
+		if queued == 0 {
+			q.queuedSince = time.Time{}
+			continue
+		}
+		if q.queuedSince.IsZero() {
+			q.queuedSince = now
+		}
+		since := q.queuedSince
+		if q.lastDone.After(since) {
+			since = q.lastDone
+		}
+		if waiting := now.Sub(since); waiting > d.Threshold {
+			return fmt.Errorf("%w: %d requests are queued, but none was done for %s", ErrStalled, queued, waiting.Round(time.Second))
+		}
+	}
+	return nil
+}
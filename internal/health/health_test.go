@@ -0,0 +1,183 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+type syncer bool
+
+func (s syncer) WaitForCacheSync(_ context.Context) bool { return bool(s) }
+
+func TestCacheSynced(t *testing.T) {
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	if err := CacheSynced(syncer(true))(req); err != nil {
+		t.Errorf("CacheSynced() error = %v, want nil", err)
+	}
+	if err := CacheSynced(syncer(false))(req); !errors.Is(err, ErrCacheNotSynced) {
+		t.Errorf("CacheSynced() error = %v, want %v", err, ErrCacheNotSynced)
+	}
+}
+
+func TestSelfCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		denied      string
+		sharedRoles bool
+		wantErr     error
+	}{
+		{
+			name:    "all permissions granted",
+			wantErr: nil,
+		},
+		{
+			name:    "escalate missing",
+			denied:  "escalate",
+			wantErr: ErrMissingPermissions,
+		},
+		{
+			name:    "patch missing for server-side apply",
+			denied:  "patch rolebindings",
+			wantErr: ErrMissingPermissions,
+		},
+		{
+			name:    "namespace patch missing",
+			denied:  "patch namespaces",
+			wantErr: ErrMissingPermissions,
+		},
+		{
+			name:    "namespace status update missing",
+			denied:  "update namespaces/status",
+			wantErr: ErrMissingPermissions,
+		},
+		{
+			name:        "clusterrole patch missing with shared roles",
+			denied:      "patch clusterroles",
+			sharedRoles: true,
+			wantErr:     ErrMissingPermissions,
+		},
+		{
+			name:    "clusterrole delete not required without shared roles",
+			denied:  "delete clusterroles",
+			wantErr: nil,
+		},
+		{
+			name:        "clusterrole delete missing with shared roles",
+			denied:      "delete clusterroles",
+			sharedRoles: true,
+			wantErr:     ErrMissingPermissions,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reviews := 0
+			c := fake.NewClientBuilder().
+				WithScheme(scheme.Scheme).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+						review := obj.(*authorizationv1.SelfSubjectAccessReview)
+						attrs := review.Spec.ResourceAttributes
+						resource := attrs.Resource
+						if attrs.Subresource != "" {
+							resource += "/" + attrs.Subresource
+						}
+						review.Status.Allowed = attrs.Verb != tt.denied && attrs.Verb+" "+resource != tt.denied
+						reviews++
+						return nil
+					},
+				}).
+				Build()
+			s := &SelfCheck{Client: c, SharedRoles: tt.sharedRoles}
+			req := httptest.NewRequest("GET", "/readyz", nil)
+			if err := s.Check(req); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("SelfCheck.Check() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// a passed check is cached
+			before := reviews
+			_ = s.Check(req)
+			if tt.wantErr == nil && reviews != before {
+				t.Errorf("SelfCheck.Check() reviewed permissions again after passing")
+			}
+		})
+	}
+}
+
+func TestStallDetector(t *testing.T) {
+	now := time.Now()
+	d := NewStallDetector(time.Minute)
+	d.now = func() time.Time { return now }
+	req := httptest.NewRequest("GET", "/healthz", nil)
+
+	done := d.Begin()
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() error = %v, want nil", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := d.Check(req); !errors.Is(err, ErrStalled) {
+		t.Errorf("StallDetector.Check() error = %v, want %v", err, ErrStalled)
+	}
+	done()
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() after reconciliation finished error = %v, want nil", err)
+	}
+
+	var disabled *StallDetector
+	disabled.Begin()()
+	if err := disabled.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() on nil detector error = %v, want nil", err)
+	}
+}
+
+func TestStallDetector_WatchQueue(t *testing.T) {
+	now := time.Now()
+	d := NewStallDetector(time.Minute)
+	d.now = func() time.Time { return now }
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	queue := WatchQueue(d, workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]()))
+	defer queue.ShutDown()
+
+	// an idle queue never stalls
+	now = now.Add(time.Hour)
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() on idle queue error = %v, want nil", err)
+	}
+
+	// requests queued after a long idle time only stall once they waited longer than the threshold
+	queue.Add("a")
+	queue.Add("b")
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() right after queueing error = %v, want nil", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := d.Check(req); !errors.Is(err, ErrStalled) {
+		t.Errorf("StallDetector.Check() with unprocessed requests error = %v, want %v", err, ErrStalled)
+	}
+
+	// a request done resets the wait, even if others are still queued
+	item, _ := queue.Get()
+	queue.Done(item)
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() after a request was done error = %v, want nil", err)
+	}
+	now = now.Add(2 * time.Minute)
+	if err := d.Check(req); !errors.Is(err, ErrStalled) {
+		t.Errorf("StallDetector.Check() with remaining request error = %v, want %v", err, ErrStalled)
+	}
+
+	item, _ = queue.Get()
+	queue.Done(item)
+	now = now.Add(2 * time.Minute)
+	if err := d.Check(req); err != nil {
+		t.Errorf("StallDetector.Check() on drained queue error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,283 @@
+// Package importer converts hand-managed Roles and RoleBindings into the permission annotations of a namespace.
+package importer
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+// ManageLabelValue is the value of the permission control label set by the generated patches
+const ManageLabelValue = "manage"
+
+// separators can't be part of any value of the annotations
+var separators = []string{",", ";", "=", "::"}
+
+// Finding describes permissions of an existing object that can't be expressed with the annotations
+type Finding struct {
+	// Object is the kind and name of the object, e.g. RoleBinding/developers
+	Object  string `json:"object"`
+	Message string `json:"message"`
+}
+
+func (f Finding) String() string {
+	return f.Object + ": " + f.Message
+}
+
+// Result is the conversion of the Roles and RoleBindings of a single namespace
+type Result struct {
+	Namespace string `json:"namespace"`
+	// Annotations are the permission annotations granting the imported permissions
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Unsupported lists the permissions that are lost by the conversion
+	Unsupported []Finding `json:"unsupported,omitempty"`
+	// Adopt are the existing objects the controller updates in place once they carry the managed labels
+	Adopt []client.Object `json:"-"`
+	// Leftovers are the imported objects the controller doesn't adopt. They have to be deleted after the migration.
+	Leftovers []string `json:"leftovers,omitempty"`
+}
+
+// Patch returns a merge patch adding the permission label and the annotations to the namespace
+func (r Result) Patch() *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Namespace"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        r.Namespace,
+			Labels:      map[string]string{controller.LabelNamespacePermissionControl: ManageLabelValue},
+			Annotations: r.Annotations,
+		},
+	}
+}
+
+// Namespace reads the Roles and RoleBindings of the namespace and converts them
+func Namespace(ctx context.Context, reader client.Reader, namespace string) (Result, error) {
+	roles := &rbacv1.RoleList{}
+	if err := reader.List(ctx, roles, client.InNamespace(namespace)); err != nil {
+		return Result{}, fmt.Errorf("unable to list roles in namespace %q: %w", namespace, err)
+	}
+	bindings := &rbacv1.RoleBindingList{}
+	if err := reader.List(ctx, bindings, client.InNamespace(namespace)); err != nil {
+		return Result{}, fmt.Errorf("unable to list rolebindings in namespace %q: %w", namespace, err)
+	}
+	return Convert(namespace, roles.Items, bindings.Items), nil
+}
+
+// Convert converts the Roles and RoleBindings of a namespace into permission annotations.
+// Objects already managed by the controller are skipped.
+//
+// The annotations express a single RoleBinding, so only RoleBindings sharing the role ref of the
+// RoleBinding named like the namespace, or of the first RoleBinding, are imported.
+// A bound Role is converted into custom rules.
+func Convert(namespace string, roles []rbacv1.Role, bindings []rbacv1.RoleBinding) Result {
+	r := Result{Namespace: namespace, Annotations: map[string]string{}}
+
+	rolesByName := map[string]*rbacv1.Role{}
+	for i := range roles {
+		if !managed(&roles[i]) {
+			rolesByName[roles[i].Name] = &roles[i]
+		}
+	}
+	var unmanaged []*rbacv1.RoleBinding
+	for i := range bindings {
+		if !managed(&bindings[i]) {
+			unmanaged = append(unmanaged, &bindings[i])
+		}
+	}
+	if len(unmanaged) == 0 {
+		return r
+	}
+	sort.SliceStable(unmanaged, func(i, j int) bool {
+		// prefer the RoleBinding the controller would adopt
+		if (unmanaged[i].Name == namespace) != (unmanaged[j].Name == namespace) {
+			return unmanaged[i].Name == namespace
+		}
+		return unmanaged[i].Name < unmanaged[j].Name
+	})
+
+	roleRef := unmanaged[0].RoleRef
+	var imported []client.Object
+	var subjects []controller.TimedSubject
+	for _, rb := range unmanaged {
+		object := ObjectName(rb)
+		if rb.RoleRef != roleRef {
+			r.unsupported(object, "binds %s %q, only RoleBindings of %s %q can be imported", rb.RoleRef.Kind, rb.RoleRef.Name, roleRef.Kind, roleRef.Name)
+			continue
+		}
+		imported = append(imported, rb)
+		for _, s := range rb.Subjects {
+			if err := checkSubject(s); err != nil {
+				r.unsupported(object, "subject %s %q: %s", s.Kind, s.Name, err)
+				continue
+			}
+			// the annotation doesn't need the default api group
+			s.APIGroup = ""
+			if !slices.ContainsFunc(subjects, func(t controller.TimedSubject) bool { return t.Subject == s }) {
+				subjects = append(subjects, controller.TimedSubject{Subject: s})
+			}
+		}
+	}
+
+	if role, ok := rolesByName[roleRef.Name]; ok && roleRef.Kind == "Role" {
+		if err := checkRules(role.Rules); err != nil {
+			// keep the existing Role and bind it instead
+			r.unsupported(ObjectName(role), "rules can't be expressed as custom rules, the Role is bound by reference: %s", err)
+			r.Annotations[controller.AnnotationNamespaceRoleBindingRoleRef] = controller.FormatRoleBindingRoleRef(roleRef)
+		} else {
+			r.Annotations[controller.AnnotationNamespaceCustomRoleRules] = controller.FormatCustomRole(role.Rules)
+			imported = append(imported, role)
+		}
+	} else {
+		if err := checkValues(roleRef.Name); err != nil {
+			r.unsupported(ObjectName(unmanaged[0]), "role ref %s %q: %s", roleRef.Kind, roleRef.Name, err)
+		} else {
+			r.Annotations[controller.AnnotationNamespaceRoleBindingRoleRef] = controller.FormatRoleBindingRoleRef(roleRef)
+		}
+	}
+	if len(subjects) > 0 {
+		r.Annotations[controller.AnnotationNamespaceRoleBindingSubjects] = controller.FormatRoleBindingSubjects(subjects)
+	}
+
+	r.adopt(imported)
+	return r
+}
+
+// adopt splits the imported objects into the ones the controller updates in place and leftovers
+func (r *Result) adopt(imported []client.Object) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: r.Namespace, Annotations: r.Annotations}}
+	desired, err := controller.Desired(ns, time.Now())
+	if err != nil {
+		// the values have been checked before, so this is a bug of the importer
+		r.unsupported("Namespace/"+r.Namespace, "generated annotations are invalid: %s", err)
+		return
+	}
+	for _, obj := range imported {
+		switch o := obj.(type) {
+		case *rbacv1.Role:
+			if desired.Role != nil && o.Name == desired.Role.Name {
+				r.Adopt = append(r.Adopt, o)
+				continue
+			}
+			r.Leftovers = append(r.Leftovers, ObjectName(o))
+		case *rbacv1.RoleBinding:
+			if desired.RoleBinding != nil && o.Name == desired.RoleBinding.Name {
+				// the role ref of a RoleBinding is immutable, so the controller can't update a different one
+				if o.RoleRef != desired.RoleBinding.RoleRef {
+					r.unsupported(ObjectName(o), "binds %s %q but the controller binds %s %q, delete it before the migration",
+						o.RoleRef.Kind, o.RoleRef.Name, desired.RoleBinding.RoleRef.Kind, desired.RoleBinding.RoleRef.Name)
+					continue
+				}
+				r.Adopt = append(r.Adopt, o)
+				continue
+			}
+			r.Leftovers = append(r.Leftovers, ObjectName(o))
+		}
+	}
+}
+
+func (r *Result) unsupported(object, format string, args ...any) {
+	r.Unsupported = append(r.Unsupported, Finding{Object: object, Message: fmt.Sprintf(format, args...)})
+}
+
+// Relabel adds the managed labels to all objects the controller adopts
+func Relabel(ctx context.Context, c client.Client, r Result) error {
+	for _, obj := range r.Adopt {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		labels := obj.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		maps.Copy(labels, map[string]string{
+			controller.LabelManagedBy:     controller.ManagedByValue,
+			controller.LabelNamespaceName: r.Namespace,
+		})
+		obj.SetLabels(labels)
+		if err := c.Patch(ctx, obj, patch); err != nil {
+			return fmt.Errorf("unable to relabel %s/%s: %w", r.Namespace, obj.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// ObjectName returns the kind and name of a Role or RoleBinding, e.g. RoleBinding/developers
+func ObjectName(obj client.Object) string {
+	switch obj.(type) {
+	case *rbacv1.Role:
+		return "Role/" + obj.GetName()
+	case *rbacv1.RoleBinding:
+		return "RoleBinding/" + obj.GetName()
+	}
+	return obj.GetName()
+}
+
+// managed reports whether the object is already managed by the controller
+func managed(obj client.Object) bool {
+	return obj.GetLabels()[controller.LabelManagedBy] == controller.ManagedByValue
+}
+
+func checkSubject(s rbacv1.Subject) error {
+	switch s.Kind {
+	case rbacv1.UserKind, rbacv1.GroupKind:
+		if s.APIGroup != "" && s.APIGroup != rbacv1.GroupName {
+			return fmt.Errorf("api group %q can't be expressed", s.APIGroup)
+		}
+	case rbacv1.ServiceAccountKind:
+		if s.APIGroup != "" {
+			return fmt.Errorf("api group %q can't be expressed", s.APIGroup)
+		}
+	default:
+		return fmt.Errorf("kind %q is not supported", s.Kind)
+	}
+	return checkValues(s.Name, s.Namespace)
+}
+
+func checkRules(rules []rbacv1.PolicyRule) error {
+	if len(rules) == 0 {
+		return fmt.Errorf("the Role has no rules")
+	}
+	for i, rule := range rules {
+		switch {
+		case len(rule.NonResourceURLs) > 0:
+			return fmt.Errorf("rule %d has non-resource URLs", i)
+		case len(rule.Verbs) == 0 || len(rule.APIGroups) == 0 || len(rule.Resources) == 0:
+			return fmt.Errorf("rule %d has no verbs, apiGroups or resources", i)
+		}
+		for _, values := range [][]string{rule.Verbs, rule.Resources, rule.ResourceNames} {
+			if slices.Contains(values, "") {
+				return fmt.Errorf("rule %d has an empty value", i)
+			}
+		}
+		if err := checkValues(slices.Concat(rule.Verbs, rule.APIGroups, rule.Resources, rule.ResourceNames)...); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	// make sure the rules survive the round trip through the annotation
+	parsed, err := controller.ParseCustomRole(controller.FormatCustomRole(rules))
+	if err != nil || !equality.Semantic.DeepEqual(parsed, rules) {
+		return fmt.Errorf("the rules change when converted")
+	}
+	return nil
+}
+
+// checkValues fails if any of the values contains a separator of the annotations
+func checkValues(values ...string) error {
+	for _, v := range values {
+		for _, sep := range separators {
+			if strings.Contains(v, sep) {
+				return fmt.Errorf("value %q contains %q", v, sep)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package importer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+func role(name string, rules ...rbacv1.PolicyRule) rbacv1.Role {
+	return rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"}, Rules: rules}
+}
+
+func roleBinding(name string, roleRef rbacv1.RoleRef, subjects ...rbacv1.Subject) rbacv1.RoleBinding {
+	return rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"}, RoleRef: roleRef, Subjects: subjects}
+}
+
+func TestConvert(t *testing.T) {
+	edit := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"}
+	view := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "view"}
+	ownRole := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}
+	developers := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "developers"}
+	jane := rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.UserKind, Name: "jane"}
+	deployer := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "ci"}
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	managed := roleBinding("break-glass-x", edit, jane)
+	managed.Labels = map[string]string{controller.LabelManagedBy: controller.ManagedByValue}
+
+	tests := []struct {
+		name            string
+		roles           []rbacv1.Role
+		bindings        []rbacv1.RoleBinding
+		wantAnnotations map[string]string
+		wantUnsupported []Finding
+		wantAdopt       []string
+		wantLeftovers   []string
+	}{
+		{
+			name:            "nothing to import",
+			bindings:        []rbacv1.RoleBinding{managed},
+			wantAnnotations: map[string]string{},
+		},
+		{
+			name: "cluster role bindings are merged",
+			bindings: []rbacv1.RoleBinding{
+				roleBinding("team-a", edit, developers, deployer),
+				roleBinding("jane", edit, jane, developers),
+				managed,
+			},
+			wantAnnotations: map[string]string{
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers,kind=ServiceAccount;name=deployer;namespace=ci,kind=User;name=jane",
+			},
+			wantAdopt:     []string{"RoleBinding/team-a"},
+			wantLeftovers: []string{"RoleBinding/jane"},
+		},
+		{
+			name:  "role is converted into custom rules",
+			roles: []rbacv1.Role{role("team-a", readPods)},
+			bindings: []rbacv1.RoleBinding{
+				roleBinding("team-a", ownRole, developers),
+			},
+			wantAnnotations: map[string]string{
+				controller.AnnotationNamespaceCustomRoleRules:     "verbs=get,list;apiGroups=;resources=pods",
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			},
+			wantAdopt: []string{"RoleBinding/team-a", "Role/team-a"},
+		},
+		{
+			name:  "role with a different name conflicts with the rolebinding of the controller",
+			roles: []rbacv1.Role{role("pod-reader", readPods)},
+			bindings: []rbacv1.RoleBinding{
+				roleBinding("team-a", rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "pod-reader"}, developers),
+			},
+			wantAnnotations: map[string]string{
+				controller.AnnotationNamespaceCustomRoleRules:     "verbs=get,list;apiGroups=;resources=pods",
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			},
+			wantUnsupported: []Finding{
+				{Object: "RoleBinding/team-a", Message: `binds Role "pod-reader" but the controller binds Role "team-a", delete it before the migration`},
+			},
+			wantLeftovers: []string{"Role/pod-reader"},
+		},
+		{
+			name:  "inexpressible role is bound by reference",
+			roles: []rbacv1.Role{role("pod-reader", rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}, ResourceNames: []string{"a,b"}})},
+			bindings: []rbacv1.RoleBinding{
+				roleBinding("readers", rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "pod-reader"}, developers),
+			},
+			wantAnnotations: map[string]string{
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=Role;apiGroup=rbac.authorization.k8s.io;name=pod-reader",
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			},
+			wantUnsupported: []Finding{
+				{Object: "Role/pod-reader", Message: `rules can't be expressed as custom rules, the Role is bound by reference: rule 0: value "a,b" contains ","`},
+			},
+			wantLeftovers: []string{"RoleBinding/readers"},
+		},
+		{
+			name: "different role refs and subjects are reported",
+			bindings: []rbacv1.RoleBinding{
+				roleBinding("editors", edit, developers, rbacv1.Subject{APIGroup: "example.com", Kind: "Robot", Name: "r2d2"}),
+				roleBinding("viewers", view, jane),
+			},
+			wantAnnotations: map[string]string{
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			},
+			wantUnsupported: []Finding{
+				{Object: "RoleBinding/editors", Message: `subject Robot "r2d2": kind "Robot" is not supported`},
+				{Object: "RoleBinding/viewers", Message: `binds ClusterRole "view", only RoleBindings of ClusterRole "edit" can be imported`},
+			},
+			wantLeftovers: []string{"RoleBinding/editors"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Convert("team-a", tt.roles, tt.bindings)
+			if diff := cmp.Diff(tt.wantAnnotations, got.Annotations); diff != "" {
+				t.Errorf("Convert() annotations mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantUnsupported, got.Unsupported); diff != "" {
+				t.Errorf("Convert() unsupported mismatch (-want +got):\n%s", diff)
+			}
+			var adopt []string
+			for _, obj := range got.Adopt {
+				adopt = append(adopt, ObjectName(obj))
+			}
+			if diff := cmp.Diff(tt.wantAdopt, adopt); diff != "" {
+				t.Errorf("Convert() adopt mismatch (-want +got):\n%s", diff)
+			}
+			if diff := cmp.Diff(tt.wantLeftovers, got.Leftovers); diff != "" {
+				t.Errorf("Convert() leftovers mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRelabel(t *testing.T) {
+	existing := roleBinding("team-a", rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"},
+		rbacv1.Subject{APIGroup: rbacv1.GroupName, Kind: rbacv1.GroupKind, Name: "developers"})
+	existing.Labels = map[string]string{"team": "a"}
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(existing.DeepCopy()).Build()
+	ctx := context.Background()
+
+	r, err := Namespace(ctx, c, "team-a")
+	if err != nil {
+		t.Fatalf("Namespace() error = %v", err)
+	}
+	if err := Relabel(ctx, c, r); err != nil {
+		t.Fatalf("Relabel() error = %v", err)
+	}
+	got := &rbacv1.RoleBinding{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(&existing), got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"team":                        "a",
+		controller.LabelManagedBy:     controller.ManagedByValue,
+		controller.LabelNamespaceName: "team-a",
+	}
+	if diff := cmp.Diff(want, got.Labels); diff != "" {
+		t.Errorf("Relabel() labels mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,210 @@
+// Package inspect answers who has access to managed namespaces, based on the RoleBindings of the controller.
+package inspect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+var (
+	ErrInvalidSubject = errors.New("invalid subject")
+)
+
+// Grant is a subject bound to a role in a namespace
+type Grant struct {
+	Subject rbacv1.Subject `json:"subject"`
+	Binding string         `json:"binding"`
+	RoleRef rbacv1.RoleRef `json:"roleRef"`
+	// Rules are the rules of the bound Role or ClusterRole
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+	// Expires is the point in time the subject loses its access, taken from the annotations of the namespace
+	Expires *time.Time `json:"expires,omitempty"`
+	// Error explains why the rules are unknown, e.g. because the bound role doesn't exist
+	Error string `json:"error,omitempty"`
+}
+
+// Access is a managed namespace a subject has access to
+type Access struct {
+	Namespace string         `json:"namespace"`
+	Binding   string         `json:"binding"`
+	RoleRef   rbacv1.RoleRef `json:"roleRef"`
+	Expires   *time.Time     `json:"expires,omitempty"`
+}
+
+// ParseSubject parses a subject given either in the format of the rolebinding-subjects annotation,
+// e.g. "kind=User;name=jane", or as Kind/name and ServiceAccount/namespace/name, e.g. "Group/developers".
+func ParseSubject(str string) (rbacv1.Subject, error) {
+	if strings.Contains(str, "=") {
+		subjects, err := controller.ParseRoleBindingSubjects(str)
+		if err != nil {
+			return rbacv1.Subject{}, fmt.Errorf("%w: %w", ErrInvalidSubject, err)
+		}
+		if len(subjects) != 1 {
+			return rbacv1.Subject{}, fmt.Errorf("%w: expected a single subject, got %d", ErrInvalidSubject, len(subjects))
+		}
+		if subjects[0].Kind == rbacv1.ServiceAccountKind && subjects[0].Namespace == "" {
+			return rbacv1.Subject{}, fmt.Errorf("%w: ServiceAccounts require a namespace", ErrInvalidSubject)
+		}
+		return subjects[0], nil
+	}
+	parts := strings.Split(str, "/")
+	switch {
+	case len(parts) == 2 && strings.EqualFold(parts[0], rbacv1.UserKind):
+		return rbacv1.Subject{Kind: rbacv1.UserKind, Name: parts[1]}, nil
+	case len(parts) == 2 && strings.EqualFold(parts[0], rbacv1.GroupKind):
+		return rbacv1.Subject{Kind: rbacv1.GroupKind, Name: parts[1]}, nil
+	case len(parts) == 3 && strings.EqualFold(parts[0], rbacv1.ServiceAccountKind):
+		return rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: parts[1], Name: parts[2]}, nil
+	}
+	return rbacv1.Subject{}, fmt.Errorf("%w: %q, use User/<name>, Group/<name>, ServiceAccount/<namespace>/<name> or kind=<kind>;name=<name>", ErrInvalidSubject, str)
+}
+
+// WhoCan returns every subject bound by a managed RoleBinding in the namespace along with the rules of the bound role
+func WhoCan(ctx context.Context, reader client.Reader, namespace string) ([]Grant, error) {
+	ns := &corev1.Namespace{}
+	if err := reader.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return nil, fmt.Errorf("unable to get namespace %q: %w", namespace, err)
+	}
+	bindings := &rbacv1.RoleBindingList{}
+	if err := reader.List(ctx, bindings, client.InNamespace(namespace), managedBindings()); err != nil {
+		return nil, fmt.Errorf("unable to list rolebindings in namespace %q: %w", namespace, err)
+	}
+	expiries := subjectExpiries(ns)
+
+	grants := []Grant{}
+	for _, rb := range bindings.Items {
+		rules, ruleErr, err := roleRules(ctx, reader, namespace, rb.RoleRef)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range rb.Subjects {
+			g := Grant{Subject: s, Binding: rb.Name, RoleRef: rb.RoleRef, Rules: rules, Error: ruleErr}
+			if rb.Name == ns.Name {
+				g.Expires = expiries[subjectKey(s, namespace)]
+			}
+			grants = append(grants, g)
+		}
+	}
+	sort.SliceStable(grants, func(i, j int) bool {
+		a, b := grants[i].Subject, grants[j].Subject
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return grants, nil
+}
+
+// Where returns every managed namespace in which the subject is bound by a managed RoleBinding
+func Where(ctx context.Context, reader client.Reader, subject rbacv1.Subject) ([]Access, error) {
+	bindings := &rbacv1.RoleBindingList{}
+	if err := reader.List(ctx, bindings, managedBindings()); err != nil {
+		return nil, fmt.Errorf("unable to list rolebindings: %w", err)
+	}
+	namespaces := map[string]*corev1.Namespace{}
+	access := []Access{}
+	for _, rb := range bindings.Items {
+		for _, s := range rb.Subjects {
+			if subjectKey(s, rb.Namespace) != subjectKey(subject, subject.Namespace) {
+				continue
+			}
+			namespace := rb.Labels[controller.LabelNamespaceName]
+			if namespace == "" {
+				namespace = rb.Namespace
+			}
+			a := Access{Namespace: namespace, Binding: rb.Name, RoleRef: rb.RoleRef}
+			if rb.Name == rb.Namespace {
+				ns, ok := namespaces[rb.Namespace]
+				if !ok {
+					ns = &corev1.Namespace{}
+					if err := reader.Get(ctx, client.ObjectKey{Name: rb.Namespace}, ns); err != nil && !apierrors.IsNotFound(err) {
+						return nil, fmt.Errorf("unable to get namespace %q: %w", rb.Namespace, err)
+					}
+					namespaces[rb.Namespace] = ns
+				}
+				a.Expires = subjectExpiries(ns)[subjectKey(s, rb.Namespace)]
+			}
+			access = append(access, a)
+			break
+		}
+	}
+	sort.SliceStable(access, func(i, j int) bool {
+		if access[i].Namespace != access[j].Namespace {
+			return access[i].Namespace < access[j].Namespace
+		}
+		return access[i].Binding < access[j].Binding
+	})
+	return access, nil
+}
+
+// managedBindings selects the RoleBindings created by the controller
+func managedBindings() client.MatchingLabels {
+	return client.MatchingLabels{controller.LabelManagedBy: controller.ManagedByValue}
+}
+
+// roleRules returns the rules of the bound role. If the role can't be found, the reason is returned as string.
+func roleRules(ctx context.Context, reader client.Reader, namespace string, roleRef rbacv1.RoleRef) ([]rbacv1.PolicyRule, string, error) {
+	var err error
+	var rules []rbacv1.PolicyRule
+	switch roleRef.Kind {
+	case "Role":
+		role := &rbacv1.Role{}
+		err = reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: roleRef.Name}, role)
+		rules = role.Rules
+	case "ClusterRole":
+		role := &rbacv1.ClusterRole{}
+		err = reader.Get(ctx, client.ObjectKey{Name: roleRef.Name}, role)
+		rules = role.Rules
+	default:
+		return nil, fmt.Sprintf("unknown role kind %q", roleRef.Kind), nil
+	}
+	if apierrors.IsNotFound(err) {
+		return nil, fmt.Sprintf("%s %q not found", roleRef.Kind, roleRef.Name), nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to get %s %q: %w", roleRef.Kind, roleRef.Name, err)
+	}
+	return rules, "", nil
+}
+
+// subjectExpiries returns the expiry of all subjects of the rolebinding-subjects annotation of the namespace
+func subjectExpiries(ns *corev1.Namespace) map[string]*time.Time {
+	expiries := map[string]*time.Time{}
+	value, ok := ns.Annotations[controller.AnnotationNamespaceRoleBindingSubjects]
+	if !ok {
+		return expiries
+	}
+	subjects, err := controller.ParseTimedRoleBindingSubjects(value)
+	if err != nil {
+		return expiries
+	}
+	for _, s := range subjects {
+		if s.Expires != nil {
+			expiries[subjectKey(s.Subject, ns.Name)] = s.Expires
+		}
+	}
+	return expiries
+}
+
+// subjectKey identifies a subject. ServiceAccounts without a namespace belong to the namespace of the binding.
+func subjectKey(s rbacv1.Subject, bindingNamespace string) string {
+	if s.Kind != rbacv1.ServiceAccountKind {
+		return s.Kind + "/" + s.Name
+	}
+	namespace := s.Namespace
+	if namespace == "" {
+		namespace = bindingNamespace
+	}
+	return s.Kind + "/" + namespace + "/" + s.Name
+}
@@ -0,0 +1,156 @@
+package inspect
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+var (
+	expires    = time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	edit       = rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "edit"}
+	readPods   = rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	editRule   = rbacv1.PolicyRule{Verbs: []string{"*"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}}
+	developers = rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "developers"}
+	jane       = rbacv1.Subject{Kind: rbacv1.UserKind, Name: "jane"}
+	deployer   = rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer"}
+)
+
+func managedRoleBinding(namespace, name string, roleRef rbacv1.RoleRef, subjects ...rbacv1.Subject) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: map[string]string{
+			controller.LabelManagedBy:     controller.ManagedByValue,
+			controller.LabelNamespaceName: namespace,
+		}},
+		RoleRef:  roleRef,
+		Subjects: subjects,
+	}
+}
+
+func newClient() client.Client {
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: map[string]string{
+			controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers,kind=User;name=jane;expires=2026-11-01T00:00:00Z,kind=ServiceAccount;name=deployer",
+			controller.AnnotationNamespaceCustomRoleRules:     "verbs=get;apiGroups=;resources=pods",
+		}}},
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Namespace: "team-a"}, Rules: []rbacv1.PolicyRule{readPods}},
+		managedRoleBinding("team-a", "team-a", rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}, developers, jane, deployer),
+		managedRoleBinding("team-a", "break-glass-incident", rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"}, jane),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-b"}},
+		&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: "edit"}, Rules: []rbacv1.PolicyRule{editRule}},
+		managedRoleBinding("team-b", "team-b", edit, developers),
+		// not managed by the controller
+		&rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "manual", Namespace: "team-b"}, RoleRef: edit, Subjects: []rbacv1.Subject{jane}},
+	).Build()
+}
+
+func TestWhoCan(t *testing.T) {
+	got, err := WhoCan(context.Background(), newClient(), "team-a")
+	if err != nil {
+		t.Fatalf("WhoCan() error = %v", err)
+	}
+	ownRole := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}
+	admin := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"}
+	want := []Grant{
+		{Subject: developers, Binding: "team-a", RoleRef: ownRole, Rules: []rbacv1.PolicyRule{readPods}},
+		{Subject: deployer, Binding: "team-a", RoleRef: ownRole, Rules: []rbacv1.PolicyRule{readPods}},
+		{Subject: jane, Binding: "break-glass-incident", RoleRef: admin, Error: `ClusterRole "admin" not found`},
+		{Subject: jane, Binding: "team-a", RoleRef: ownRole, Rules: []rbacv1.PolicyRule{readPods}, Expires: &expires},
+	}
+	if diff := cmp.Diff(want, got); diff != "" {
+		t.Errorf("WhoCan() mismatch (-want +got):\n%s", diff)
+	}
+}
+
+func TestWhere(t *testing.T) {
+	tests := []struct {
+		name    string
+		subject rbacv1.Subject
+		want    []Access
+	}{
+		{
+			name:    "group",
+			subject: developers,
+			want: []Access{
+				{Namespace: "team-a", Binding: "team-a", RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}},
+				{Namespace: "team-b", Binding: "team-b", RoleRef: edit},
+			},
+		},
+		{
+			name:    "user with expiry, unmanaged bindings are ignored",
+			subject: jane,
+			want: []Access{
+				{Namespace: "team-a", Binding: "break-glass-incident", RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "admin"}},
+				{Namespace: "team-a", Binding: "team-a", RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}, Expires: &expires},
+			},
+		},
+		{
+			name:    "service account without namespace belongs to the namespace of the binding",
+			subject: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "deployer", Namespace: "team-a"},
+			want: []Access{
+				{Namespace: "team-a", Binding: "team-a", RoleRef: rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "team-a"}},
+			},
+		},
+		{
+			name:    "no access",
+			subject: rbacv1.Subject{Kind: rbacv1.UserKind, Name: "nobody"},
+			want:    []Access{},
+		},
+	}
+	c := newClient()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Where(context.Background(), c, tt.subject)
+			if err != nil {
+				t.Fatalf("Where() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Where() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestParseSubject(t *testing.T) {
+	tests := []struct {
+		str     string
+		want    rbacv1.Subject
+		wantErr bool
+	}{
+		{str: "User/jane", want: jane},
+		{str: "group/developers", want: developers},
+		{str: "ServiceAccount/ci/deployer", want: rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: "ci", Name: "deployer"}},
+		{str: "kind=User;name=jane", want: jane},
+		{str: "kind=ServiceAccount;name=deployer", wantErr: true},
+		{str: "kind=User;name=a,kind=User;name=b", wantErr: true},
+		{str: "Robot/r2d2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			got, err := ParseSubject(tt.str)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSubject) {
+					t.Errorf("ParseSubject() error = %v, want %v", err, ErrInvalidSubject)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseSubject() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSubject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+// maxConfigMapSize is the maximum size of the data of a ConfigMap
+const maxConfigMapSize = 1 << 20
+
+var (
+	ErrTooLarge = errors.New("inventory exceeds the maximum size of a ConfigMap")
+)
+
+var (
+	_ manager.Runnable               = &ConfigMapWriter{}
+	_ manager.LeaderElectionRunnable = &ConfigMapWriter{}
+)
+
+var inventorylog = logf.Log.WithName("inventory")
+
+// ConfigMapWriter periodically writes the inventory in all Formats to a ConfigMap.
+// It only runs on the leader.
+type ConfigMapWriter struct {
+	// Reader reads namespaces, Roles, RoleBindings and the ConfigMap. It should read from the API server directly.
+	Reader client.Reader
+	// Client creates and updates the ConfigMap
+	Client client.Client
+	// Guard excludes protected namespaces. A nil Guard protects nothing.
+	Guard *controller.Guard
+	// Namespace and Name of the ConfigMap
+	Namespace string
+	Name      string
+	// Interval between two reports
+	Interval time.Duration
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (w *ConfigMapWriter) NeedLeaderElection() bool {
+	return true
+}
+
+// Start implements manager.Runnable. It writes a report right away and then once per interval.
+// Failures are logged and retried with the next report.
+func (w *ConfigMapWriter) Start(ctx context.Context) error {
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Write(ctx, time.Now()); err != nil {
+			inventorylog.Error(err, "unable to write inventory", "namespace", w.Namespace, "name", w.Name)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Write collects the inventory and writes it to the ConfigMap
+func (w *ConfigMapWriter) Write(ctx context.Context, now time.Time) error {
+	namespaces, err := Collect(ctx, w.Reader, w.Guard, "", now)
+	if err != nil {
+		return err
+	}
+	report := Report{GeneratedAt: now.UTC(), Namespaces: namespaces}
+	data := map[string]string{}
+	size := 0
+	for _, format := range Formats {
+		buf := &bytes.Buffer{}
+		if err := Write(buf, format, report); err != nil {
+			return err
+		}
+		data[FileName(format)] = buf.String()
+		size += buf.Len()
+	}
+	if size > maxConfigMapSize {
+		return fmt.Errorf("%w: %d bytes", ErrTooLarge, size)
+	}
+
+	cm := &corev1.ConfigMap{}
+	err = w.Reader.Get(ctx, client.ObjectKey{Namespace: w.Namespace, Name: w.Name}, cm)
+	if apierrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      w.Name,
+				Namespace: w.Namespace,
+				Labels:    map[string]string{controller.LabelManagedBy: controller.ManagedByValue},
+			},
+			Data: data,
+		}
+		return w.Client.Create(ctx, cm)
+	}
+	if err != nil {
+		return err
+	}
+	cm.Data = data
+	return w.Client.Update(ctx, cm)
+}
@@ -0,0 +1,175 @@
+// Package inventory collects the desired and actual permissions of all managed namespaces.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+// Parsed holds the permissions parsed from the annotations of a namespace
+type Parsed struct {
+	Subjects []controller.TimedSubject `json:"subjects,omitempty"`
+	RoleRef  *rbacv1.RoleRef           `json:"roleRef,omitempty"`
+	Rules    []rbacv1.PolicyRule       `json:"rules,omitempty"`
+	// Errors lists the annotations that can't be parsed
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// Live holds the Role and RoleBinding of a namespace as found in the cluster
+type Live struct {
+	Role        *rbacv1.Role        `json:"role,omitempty"`
+	RoleBinding *rbacv1.RoleBinding `json:"roleBinding,omitempty"`
+	// ClusterRole is the shared ClusterRole the RoleBinding is bound to instead of the Role, if any
+	ClusterRole *rbacv1.ClusterRole `json:"clusterRole,omitempty"`
+}
+
+// NamespacePermissions compares the desired and actual permissions of a managed namespace
+type NamespacePermissions struct {
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+	Parsed      Parsed            `json:"parsed"`
+	Live        Live              `json:"live"`
+	// InSync is true if the live objects match the parsed annotations
+	InSync bool `json:"inSync"`
+	// Differences explains why the namespace is not in sync
+	Differences []string `json:"differences,omitempty"`
+	// Paused is true if the reconciliation of the namespace is paused, so differences are expected
+	Paused bool `json:"paused,omitempty"`
+}
+
+// Collect inspects every namespace carrying the permission-control label, except the protected ones.
+// If namespace is not empty, only that namespace is inspected. The reader should read from the API server
+// directly, so the controller doesn't need to cache all Roles and RoleBindings of the cluster.
+func Collect(ctx context.Context, reader client.Reader, guard *controller.Guard, namespace string, now time.Time) ([]NamespacePermissions, error) {
+	list := &corev1.NamespaceList{}
+	if err := reader.List(ctx, list, client.HasLabels{controller.LabelNamespacePermissionControl}); err != nil {
+		return nil, fmt.Errorf("unable to list namespaces: %w", err)
+	}
+	result := []NamespacePermissions{}
+	for i := range list.Items {
+		ns := &list.Items[i]
+		if (namespace != "" && ns.Name != namespace) || guard.IsProtectedNamespace(ns.Name) {
+			continue
+		}
+		p, err := Inspect(ctx, reader, ns, now)
+		if err != nil {
+			return nil, fmt.Errorf("unable to inspect namespace %q: %w", ns.Name, err)
+		}
+		result = append(result, p)
+	}
+	return result, nil
+}
+
+// Inspect compares the permission annotations of the namespace with its live Role and RoleBinding
+func Inspect(ctx context.Context, reader client.Reader, ns *corev1.Namespace, now time.Time) (NamespacePermissions, error) {
+	p := NamespacePermissions{
+		Namespace:   ns.Name,
+		Annotations: map[string]string{},
+		Parsed:      parse(ns, now),
+	}
+	for _, annotation := range controller.PermissionAnnotations {
+		if value, ok := ns.Annotations[annotation]; ok {
+			p.Annotations[annotation] = value
+		}
+	}
+
+	role := &rbacv1.Role{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: ns.Name}, role); err == nil {
+		p.Live.Role = role
+	} else if !errors.IsNotFound(err) {
+		return p, err
+	}
+	rb := &rbacv1.RoleBinding{}
+	if err := reader.Get(ctx, client.ObjectKey{Namespace: ns.Name, Name: ns.Name}, rb); err == nil {
+		p.Live.RoleBinding = rb
+	} else if !errors.IsNotFound(err) {
+		return p, err
+	}
+	if p.Live.RoleBinding != nil && controller.IsSharedRoleRef(rb.RoleRef) {
+		cr := &rbacv1.ClusterRole{}
+		if err := reader.Get(ctx, client.ObjectKey{Name: rb.RoleRef.Name}, cr); err == nil {
+			p.Live.ClusterRole = cr
+		} else if !errors.IsNotFound(err) {
+			return p, err
+		}
+	}
+
+	p.Differences = compare(ns, p.Parsed, p.Live, now)
+	p.InSync = len(p.Differences) == 0
+	// an invalid pause annotation doesn't pause the namespace and shows up in its lint findings instead
+	pause, _ := controller.PauseOf(ns, now)
+	p.Paused = pause.Paused
+	return p, nil
+}
+
+// parse parses the permission annotations with the desired state computation of the controller
+func parse(ns *corev1.Namespace, now time.Time) Parsed {
+	desired, err := controller.Desired(ns, now)
+	p := Parsed{Subjects: desired.Subjects, Rules: desired.Rules}
+	if desired.RoleRef != (rbacv1.RoleRef{}) {
+		p.RoleRef = &desired.RoleRef
+	}
+	for _, ae := range controller.AnnotationErrors(err) {
+		if p.Errors == nil {
+			p.Errors = map[string]string{}
+		}
+		p.Errors[ae.Annotation] = ae.Err.Error()
+	}
+	return p
+}
+
+// compare lists the differences between the parsed annotations and the live objects
+func compare(ns *corev1.Namespace, parsed Parsed, live Live, now time.Time) []string {
+	var diffs []string
+	for _, annotation := range controller.PermissionAnnotations {
+		if _, ok := parsed.Errors[annotation]; ok {
+			diffs = append(diffs, fmt.Sprintf("annotation %s can't be parsed", annotation))
+		}
+	}
+	// namespaces sharing their custom rules are bound to a shared ClusterRole instead of a Role
+	_, hasRules := ns.Annotations[controller.AnnotationNamespaceCustomRoleRules]
+	shared := hasRules && parsed.Rules != nil && live.RoleBinding != nil && controller.IsSharedRoleRef(live.RoleBinding.RoleRef)
+	if hasRules && parsed.Rules != nil {
+		switch {
+		case shared && live.ClusterRole == nil:
+			diffs = append(diffs, "shared clusterrole is missing")
+		case shared:
+			if !equality.Semantic.DeepEqual(controller.NormalizeRules(parsed.Rules), live.ClusterRole.Rules) {
+				diffs = append(diffs, "shared clusterrole rules differ")
+			}
+		case live.Role == nil:
+			diffs = append(diffs, "role is missing")
+		case !equality.Semantic.DeepEqual(parsed.Rules, live.Role.Rules):
+			diffs = append(diffs, "role rules differ")
+		}
+	}
+	if _, ok := ns.Annotations[controller.AnnotationNamespaceRoleBindingSubjects]; ok && parsed.Subjects != nil {
+		active, _, _ := controller.ActiveSubjects(parsed.Subjects, now)
+		switch {
+		case live.RoleBinding == nil:
+			diffs = append(diffs, "rolebinding is missing")
+		default:
+			if !equality.Semantic.DeepEqual(active, live.RoleBinding.Subjects) {
+				diffs = append(diffs, "rolebinding subjects differ")
+			}
+			roleRef := parsed.RoleRef
+			if shared {
+				sharedRef := controller.SharedRoleRef(parsed.Rules)
+				roleRef = &sharedRef
+			}
+			if roleRef != nil && *roleRef != live.RoleBinding.RoleRef {
+				diffs = append(diffs, "rolebinding roleRef differs")
+			}
+		}
+	}
+	return diffs
+}
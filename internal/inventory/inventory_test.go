@@ -0,0 +1,226 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+var now = time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+
+func newClient() client.Client {
+	managed := func(name string, annotations map[string]string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Labels:      map[string]string{controller.LabelNamespacePermissionControl: "manage"},
+			Annotations: annotations,
+		}}
+	}
+	readPods := rbacv1.PolicyRule{Verbs: []string{"get"}, APIGroups: []string{""}, Resources: []string{"pods"}}
+	ownRole := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "in-sync"}
+	return fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+		managed("in-sync", map[string]string{
+			controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers,kind=User;name=contractor;expires=2026-09-01T00:00:00Z",
+			controller.AnnotationNamespaceCustomRoleRules:     "verbs=get;apiGroups=;resources=pods",
+		}),
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "in-sync", Namespace: "in-sync"}, Rules: []rbacv1.PolicyRule{readPods}},
+		&rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "in-sync", Namespace: "in-sync"},
+			RoleRef:    ownRole,
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "developers"}},
+		},
+		managed("drifted", map[string]string{
+			controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			controller.AnnotationNamespaceCustomRoleRules:     "verbs=get,list;apiGroups=;resources=pods",
+		}),
+		&rbacv1.Role{ObjectMeta: metav1.ObjectMeta{Name: "drifted", Namespace: "drifted"}, Rules: []rbacv1.PolicyRule{readPods}},
+		managed("kube-system", map[string]string{
+			controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+		}),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "unmanaged"}},
+	).Build()
+}
+
+func TestCollect(t *testing.T) {
+	got, err := Collect(context.Background(), newClient(), &controller.Guard{ProtectedNamespaces: []string{"kube-*"}}, "", now)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	differences := map[string][]string{}
+	for _, p := range got {
+		differences[p.Namespace] = p.Differences
+	}
+	want := map[string][]string{
+		"in-sync": nil,
+		"drifted": {"role rules differ", "rolebinding is missing"},
+	}
+	if diff := cmp.Diff(want, differences); diff != "" {
+		t.Errorf("Collect() differences mismatch (-want +got):\n%s", diff)
+	}
+
+	got, err = Collect(context.Background(), newClient(), nil, "drifted", now)
+	if err != nil {
+		t.Fatalf("Collect() error = %v", err)
+	}
+	if len(got) != 1 || got[0].Namespace != "drifted" {
+		t.Errorf("Collect() with namespace filter = %v, want only drifted", got)
+	}
+}
+
+func TestInspect_SharedRole(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "team",
+		Labels: map[string]string{controller.LabelNamespacePermissionControl: "manage"},
+		Annotations: map[string]string{
+			controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=developers",
+			controller.AnnotationNamespaceCustomRoleRules:     "verbs=list,get;apiGroups=;resources=pods",
+		},
+	}}
+	rules := []rbacv1.PolicyRule{{Verbs: []string{"get", "list"}, APIGroups: []string{""}, Resources: []string{"pods"}}}
+	clusterRole := &rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: controller.SharedRoleName(rules)}, Rules: rules}
+	binding := func(roleRef rbacv1.RoleRef) *rbacv1.RoleBinding {
+		return &rbacv1.RoleBinding{
+			ObjectMeta: metav1.ObjectMeta{Name: "team", Namespace: "team"},
+			RoleRef:    roleRef,
+			Subjects:   []rbacv1.Subject{{Kind: rbacv1.GroupKind, Name: "developers"}},
+		}
+	}
+	stale := rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: controller.SharedRolePrefix + "0123456789abcdef"}
+
+	tests := []struct {
+		name string
+		objs []client.Object
+		want []string
+	}{
+		{
+			name: "in sync",
+			objs: []client.Object{clusterRole, binding(controller.SharedRoleRef(rules))},
+		},
+		{
+			name: "shared clusterrole missing",
+			objs: []client.Object{binding(controller.SharedRoleRef(rules))},
+			want: []string{"shared clusterrole is missing"},
+		},
+		{
+			name: "bound to clusterrole of previous rules",
+			objs: []client.Object{
+				&rbacv1.ClusterRole{ObjectMeta: metav1.ObjectMeta{Name: stale.Name}, Rules: rules[:0]},
+				binding(stale),
+			},
+			want: []string{"shared clusterrole rules differ", "rolebinding roleRef differs"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.objs...).Build()
+			got, err := Inspect(context.Background(), c, ns, now)
+			if err != nil {
+				t.Fatalf("Inspect() error = %v", err)
+			}
+			if diff := cmp.Diff(tt.want, got.Differences); diff != "" {
+				t.Errorf("Inspect() differences mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	namespaces, err := Collect(context.Background(), newClient(), nil, "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := Report{GeneratedAt: now, Namespaces: namespaces}
+
+	buf := &bytes.Buffer{}
+	if err := Write(buf, FormatCSV, report); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	rows, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	wantRows := [][]string{
+		csvHeader,
+		{"drifted", "Group", "developers", "", "", "Role/drifted", "verbs=get,list;apiGroups=;resources=pods", "true", "false", "false", "role rules differ; rolebinding is missing"},
+		{"in-sync", "Group", "developers", "", "", "Role/in-sync", "verbs=get;apiGroups=;resources=pods", "true", "true", "true", ""},
+		{"in-sync", "User", "contractor", "", "2026-09-01T00:00:00Z", "Role/in-sync", "verbs=get;apiGroups=;resources=pods", "true", "true", "true", ""},
+		{"kube-system", "Group", "developers", "", "", "", "", "false", "false", "false", "rolebinding is missing"},
+	}
+	if diff := cmp.Diff(wantRows, rows); diff != "" {
+		t.Errorf("Write() CSV mismatch (-want +got):\n%s", diff)
+	}
+
+	buf.Reset()
+	if err := Write(buf, FormatMarkdown, report); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	for _, want := range []string{
+		"3 managed namespaces, 1 in sync, 2 with mismatches.",
+		"| in-sync | Group/developers<br>User/contractor (until 2026-09-01T00:00:00Z) | Role/in-sync |",
+		"| role rules differ<br>rolebinding is missing |",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Write() markdown misses %q:\n%s", want, buf.String())
+		}
+	}
+
+	buf.Reset()
+	if err := Write(buf, FormatJSON, report); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), `"generatedAt": "2026-10-01T00:00:00Z"`) {
+		t.Errorf("Write() JSON misses the generation time:\n%s", buf.String())
+	}
+
+	if err := Write(buf, "xml", report); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("Write() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestConfigMapWriter(t *testing.T) {
+	c := newClient()
+	w := &ConfigMapWriter{Reader: c, Client: c, Namespace: "system", Name: "inventory", Interval: time.Hour}
+	ctx := context.Background()
+	key := client.ObjectKey{Namespace: "system", Name: "inventory"}
+
+	if err := w.Write(ctx, now); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	cm := &corev1.ConfigMap{}
+	if err := c.Get(ctx, key, cm); err != nil {
+		t.Fatalf("ConfigMap not created: %v", err)
+	}
+	for _, format := range Formats {
+		if cm.Data[FileName(format)] == "" {
+			t.Errorf("ConfigMap misses %s", FileName(format))
+		}
+	}
+	if cm.Labels[controller.LabelManagedBy] != controller.ManagedByValue {
+		t.Errorf("ConfigMap labels = %v, want managed-by label", cm.Labels)
+	}
+
+	later := now.Add(time.Hour)
+	if err := w.Write(ctx, later); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if err := c.Get(ctx, key, cm); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(cm.Data[FileName(FormatMarkdown)], later.Format(time.RFC3339)) {
+		t.Errorf("ConfigMap not updated:\n%s", cm.Data[FileName(FormatMarkdown)])
+	}
+}
@@ -0,0 +1,167 @@
+package inventory
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+const (
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+	FormatMarkdown = "markdown"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown inventory format")
+)
+
+// Formats are all supported report formats
+var Formats = []string{FormatCSV, FormatJSON, FormatMarkdown}
+
+// Report is the inventory of all managed namespaces at a point in time
+type Report struct {
+	GeneratedAt time.Time              `json:"generatedAt"`
+	Namespaces  []NamespacePermissions `json:"namespaces"`
+}
+
+// FileName returns the name of the report file in the given format, e.g. inventory.csv
+func FileName(format string) string {
+	if format == FormatMarkdown {
+		return "inventory.md"
+	}
+	return "inventory." + format
+}
+
+// Write writes the report in the given format
+func Write(w io.Writer, format string, r Report) error {
+	switch format {
+	case FormatCSV:
+		return writeCSV(w, r)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(r)
+	case FormatMarkdown:
+		return writeMarkdown(w, r)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownFormat, format)
+}
+
+var csvHeader = []string{
+	"namespace", "subject_kind", "subject_name", "subject_namespace", "subject_expires",
+	"role_ref", "custom_rules", "role_exists", "rolebinding_exists", "in_sync", "differences",
+}
+
+// writeCSV writes a row per subject, namespaces without subjects get a single row
+func writeCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, p := range r.Namespaces {
+		common := func(row []string) []string {
+			return append(row, roleRef(p), customRules(p),
+				strconv.FormatBool(p.Live.Role != nil), strconv.FormatBool(p.Live.RoleBinding != nil),
+				strconv.FormatBool(p.InSync), strings.Join(p.Differences, "; "))
+		}
+		if len(p.Parsed.Subjects) == 0 {
+			if err := cw.Write(common([]string{p.Namespace, "", "", "", ""})); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, s := range p.Parsed.Subjects {
+			if err := cw.Write(common([]string{p.Namespace, s.Kind, s.Name, s.Namespace, expiry(s)})); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeMarkdown writes a summary table with a row per namespace
+func writeMarkdown(w io.Writer, r Report) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Namespace permission inventory\n\nGenerated at %s.\n\n", r.GeneratedAt.UTC().Format(time.RFC3339))
+	inSync := 0
+	for _, p := range r.Namespaces {
+		if p.InSync {
+			inSync++
+		}
+	}
+	fmt.Fprintf(&b, "%d managed namespaces, %d in sync, %d with mismatches.\n\n", len(r.Namespaces), inSync, len(r.Namespaces)-inSync)
+	b.WriteString("| Namespace | Subjects | Role | Custom rules | Role exists | RoleBinding exists | In sync | Differences |\n")
+	b.WriteString("|---|---|---|---|---|---|---|---|\n")
+	for _, p := range r.Namespaces {
+		subjects := make([]string, 0, len(p.Parsed.Subjects))
+		for _, s := range p.Parsed.Subjects {
+			subject := s.Kind + "/" + s.Name
+			if s.Namespace != "" {
+				subject = s.Kind + "/" + s.Namespace + "/" + s.Name
+			}
+			if s.Expires != nil {
+				subject += " (until " + expiry(s) + ")"
+			}
+			subjects = append(subjects, subject)
+		}
+		cells := []string{
+			p.Namespace,
+			strings.Join(subjects, "<br>"),
+			roleRef(p),
+			strings.ReplaceAll(customRules(p), "::", "<br>"),
+			yesNo(p.Live.Role != nil),
+			yesNo(p.Live.RoleBinding != nil),
+			yesNo(p.InSync),
+			strings.Join(p.Differences, "<br>"),
+		}
+		for i, c := range cells {
+			cells[i] = strings.ReplaceAll(c, "|", `\|`)
+		}
+		fmt.Fprintf(&b, "| %s |\n", strings.Join(cells, " | "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func roleRef(p NamespacePermissions) string {
+	if p.Parsed.RoleRef == nil {
+		return ""
+	}
+	return p.Parsed.RoleRef.Kind + "/" + p.Parsed.RoleRef.Name
+}
+
+func customRules(p NamespacePermissions) string {
+	if len(p.Parsed.Rules) == 0 {
+		return ""
+	}
+	return controller.FormatCustomRole(p.Parsed.Rules)
+}
+
+func expiry(s controller.TimedSubject) string {
+	if s.Expires == nil {
+		return ""
+	}
+	return s.Expires.UTC().Format(time.RFC3339)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// ValidFormat reports whether format is one of Formats
+func ValidFormat(format string) bool {
+	return slices.Contains(Formats, format)
+}
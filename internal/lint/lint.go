@@ -0,0 +1,180 @@
+// Package lint validates the permission annotations of namespaces without a cluster.
+package lint
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+// Severity of a finding
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Finding is a problem found in the annotations of a namespace
+type Finding struct {
+	Severity Severity `json:"severity"`
+	// Annotation is the annotation the finding refers to. It is empty for findings about the namespace itself.
+	Annotation string `json:"annotation,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (f Finding) String() string {
+	if f.Annotation == "" {
+		return fmt.Sprintf("%s: %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Severity, f.Annotation, f.Message)
+}
+
+// Namespace parses the permission annotations of the namespace like the controller does
+// and checks the result for values the API server or the controller would reject.
+func Namespace(ns *corev1.Namespace, now time.Time) []Finding {
+	l := &linter{}
+	_, labelled := ns.Labels[controller.LabelNamespacePermissionControl]
+	hasAnnotations := false
+	for _, annotation := range controller.PermissionAnnotations {
+		if _, ok := ns.Annotations[annotation]; ok {
+			hasAnnotations = true
+		}
+	}
+	if !labelled {
+		if hasAnnotations {
+			l.warn("", "permission annotations are ignored without the %s label", controller.LabelNamespacePermissionControl)
+		}
+		return l.findings
+	}
+
+	// the same computation as in the controller, invalid annotations are reported as errors
+	desired, err := controller.Desired(ns, now)
+	invalid := map[string]error{}
+	for _, ae := range controller.AnnotationErrors(err) {
+		invalid[ae.Annotation] = ae.Err
+	}
+	_, hasRoleRef := ns.Annotations[controller.AnnotationNamespaceRoleBindingRoleRef]
+	_, hasCustomRules := ns.Annotations[controller.AnnotationNamespaceCustomRoleRules]
+	_, hasSubjects := ns.Annotations[controller.AnnotationNamespaceRoleBindingSubjects]
+
+	if hasRoleRef {
+		if err := invalid[controller.AnnotationNamespaceRoleBindingRoleRef]; err != nil {
+			l.error(controller.AnnotationNamespaceRoleBindingRoleRef, "%s", err)
+		} else if !hasCustomRules || invalid[controller.AnnotationNamespaceCustomRoleRules] != nil {
+			// valid custom rules replace the role ref
+			l.roleRef(desired.RoleRef)
+		}
+	}
+
+	if hasCustomRules {
+		if err := invalid[controller.AnnotationNamespaceCustomRoleRules]; err != nil {
+			l.error(controller.AnnotationNamespaceCustomRoleRules, "%s", err)
+		} else {
+			l.rules(desired.Rules)
+		}
+		if hasRoleRef {
+			l.warn(controller.AnnotationNamespaceRoleBindingRoleRef, "ignored, the custom role takes precedence")
+		}
+	}
+
+	if hasSubjects {
+		if err := invalid[controller.AnnotationNamespaceRoleBindingSubjects]; err != nil {
+			l.error(controller.AnnotationNamespaceRoleBindingSubjects, "%s", err)
+		} else {
+			l.subjects(desired.Subjects, now)
+		}
+		if !hasRoleRef && !hasCustomRules {
+			l.error(controller.AnnotationNamespaceRoleBindingSubjects, "subjects require either %s or %s",
+				controller.AnnotationNamespaceRoleBindingRoleRef, controller.AnnotationNamespaceCustomRoleRules)
+		}
+	} else if hasRoleRef || hasCustomRules {
+		l.warn("", "no subjects are bound, %s is missing", controller.AnnotationNamespaceRoleBindingSubjects)
+	}
+
+	pause, err := controller.PauseOf(ns, now)
+	switch {
+	case err != nil:
+		l.error(controller.AnnotationReconcilePaused, "%s", err)
+	case pause.Paused:
+		l.warn(controller.AnnotationReconcilePaused, "reconciliation is paused, changes are not applied")
+	case pause.Expired:
+		l.warn(controller.AnnotationReconcilePausedUntil, "the pause expired at %s and can be removed", pause.Until.UTC().Format(time.RFC3339))
+	}
+	return l.findings
+}
+
+type linter struct {
+	findings []Finding
+}
+
+func (l *linter) error(annotation, format string, args ...any) {
+	l.findings = append(l.findings, Finding{Severity: SeverityError, Annotation: annotation, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) warn(annotation, format string, args ...any) {
+	l.findings = append(l.findings, Finding{Severity: SeverityWarning, Annotation: annotation, Message: fmt.Sprintf(format, args...)})
+}
+
+func (l *linter) roleRef(roleRef rbacv1.RoleRef) {
+	const annotation = controller.AnnotationNamespaceRoleBindingRoleRef
+	switch roleRef.Kind {
+	case "Role", "ClusterRole":
+	default:
+		l.error(annotation, "kind has to be Role or ClusterRole, got %q", roleRef.Kind)
+	}
+	if roleRef.Name == "" {
+		l.error(annotation, "name is required")
+	}
+	if roleRef.APIGroup != rbacv1.GroupName {
+		l.error(annotation, "apiGroup has to be %q, got %q", rbacv1.GroupName, roleRef.APIGroup)
+	}
+}
+
+func (l *linter) rules(rules []rbacv1.PolicyRule) {
+	const annotation = controller.AnnotationNamespaceCustomRoleRules
+	if len(rules) == 0 {
+		l.error(annotation, "no rules defined")
+	}
+	for i, rule := range rules {
+		if len(rule.Verbs) == 0 || slices.Contains(rule.Verbs, "") {
+			l.error(annotation, "rule %d has missing or empty verbs", i)
+		}
+		if len(rule.Resources) == 0 || slices.Contains(rule.Resources, "") {
+			l.error(annotation, "rule %d has missing or empty resources", i)
+		}
+		if len(rule.APIGroups) == 0 {
+			l.error(annotation, "rule %d has no apiGroups, use \"apiGroups=\" for the core group", i)
+		}
+	}
+}
+
+func (l *linter) subjects(subjects []controller.TimedSubject, now time.Time) {
+	const annotation = controller.AnnotationNamespaceRoleBindingSubjects
+	if len(subjects) == 0 {
+		l.error(annotation, "no subjects defined")
+	}
+	for i, s := range subjects {
+		switch s.Kind {
+		case rbacv1.UserKind, rbacv1.GroupKind:
+			if s.Namespace != "" {
+				l.error(annotation, "subject %d: namespace is only allowed for ServiceAccounts", i)
+			}
+		case rbacv1.ServiceAccountKind:
+			// an empty namespace refers to the namespace of the RoleBinding
+		default:
+			l.error(annotation, "subject %d: kind has to be User, Group or ServiceAccount, got %q", i, s.Kind)
+		}
+		if s.Name == "" {
+			l.error(annotation, "subject %d: name is required", i)
+		}
+		if s.Expires != nil && !now.Before(*s.Expires) {
+			l.warn(annotation, "subject %d: access of %s %q expired at %s", i, s.Kind, s.Name, s.Expires.Format(time.RFC3339))
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package lint
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+func namespace(labelled bool, annotations map[string]string) *corev1.Namespace {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a", Annotations: annotations}}
+	if labelled {
+		ns.Labels = map[string]string{controller.LabelNamespacePermissionControl: "manage"}
+	}
+	return ns
+}
+
+func TestNamespace(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		ns   *corev1.Namespace
+		want []Finding
+	}{
+		{
+			name: "valid role ref",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-a,kind=ServiceAccount;name=deployer",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			}),
+		},
+		{
+			name: "valid custom rules",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceCustomRoleRules:     "verbs=get,list;apiGroups=;resources=pods",
+			}),
+		},
+		{
+			name: "unmanaged namespace without annotations",
+			ns:   namespace(false, nil),
+		},
+		{
+			name: "annotations without label",
+			ns: namespace(false, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+			}),
+			want: []Finding{
+				{Severity: SeverityWarning, Message: "permission annotations are ignored without the ns.tagesspiegel.de/permission-control label"},
+			},
+		},
+		{
+			name: "invalid role ref",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=Cluster;name=edit",
+			}),
+			want: []Finding{
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceRoleBindingRoleRef, Message: `kind has to be Role or ClusterRole, got "Cluster"`},
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceRoleBindingRoleRef, Message: `apiGroup has to be "rbac.authorization.k8s.io", got ""`},
+			},
+		},
+		{
+			name: "rule without api groups",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceCustomRoleRules:     "verbs=get;resources=pods",
+			}),
+			want: []Finding{
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceCustomRoleRules, Message: `rule 0 has no apiGroups, use "apiGroups=" for the core group`},
+			},
+		},
+		{
+			name: "invalid and expired subjects",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=Robot;name=r2d2,kind=User;name=jane;namespace=team-a,kind=User;name=contractor;expires=2026-09-01T00:00:00Z",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+			}),
+			want: []Finding{
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceRoleBindingSubjects, Message: `subject 0: kind has to be User, Group or ServiceAccount, got "Robot"`},
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceRoleBindingSubjects, Message: "subject 1: namespace is only allowed for ServiceAccounts"},
+				{Severity: SeverityWarning, Annotation: controller.AnnotationNamespaceRoleBindingSubjects, Message: `subject 2: access of User "contractor" expired at 2026-09-01T00:00:00Z`},
+			},
+		},
+		{
+			name: "subjects without role",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+			}),
+			want: []Finding{
+				{Severity: SeverityError, Annotation: controller.AnnotationNamespaceRoleBindingSubjects, Message: "subjects require either ns.tagesspiegel.de/rolebinding-roleref or ns.tagesspiegel.de/custom-role-rules"},
+			},
+		},
+		{
+			name: "role ref and custom rules without subjects",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationNamespaceCustomRoleRules:    "verbs=get;apiGroups=;resources=pods",
+			}),
+			want: []Finding{
+				{Severity: SeverityWarning, Annotation: controller.AnnotationNamespaceRoleBindingRoleRef, Message: "ignored, the custom role takes precedence"},
+				{Severity: SeverityWarning, Message: "no subjects are bound, ns.tagesspiegel.de/rolebinding-subjects is missing"},
+			},
+		},
+		{
+			name: "paused reconciliation",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationReconcilePaused:              "true",
+				controller.AnnotationReconcilePausedUntil:         "2026-10-02T00:00:00Z",
+			}),
+			want: []Finding{
+				{Severity: SeverityWarning, Annotation: controller.AnnotationReconcilePaused, Message: "reconciliation is paused, changes are not applied"},
+			},
+		},
+		{
+			name: "expired pause",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationReconcilePaused:              "true",
+				controller.AnnotationReconcilePausedUntil:         "2026-09-01T00:00:00Z",
+			}),
+			want: []Finding{
+				{Severity: SeverityWarning, Annotation: controller.AnnotationReconcilePausedUntil, Message: "the pause expired at 2026-09-01T00:00:00Z and can be removed"},
+			},
+		},
+		{
+			name: "invalid pause",
+			ns: namespace(true, map[string]string{
+				controller.AnnotationNamespaceRoleBindingSubjects: "kind=User;name=jane",
+				controller.AnnotationNamespaceRoleBindingRoleRef:  "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				controller.AnnotationReconcilePaused:              "yes",
+			}),
+			want: []Finding{
+				{Severity: SeverityError, Annotation: controller.AnnotationReconcilePaused, Message: `invalid pause annotation ns.tagesspiegel.de/reconcile-paused="yes": expected true or false`},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Namespace(tt.ns, now)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("Namespace() mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Source: "ns.yaml", Document: 0, Namespace: "team-a"},
+		{Source: "ns.yaml", Document: 1, Namespace: "team-b", Findings: []Finding{
+			{Severity: SeverityError, Annotation: "a", Message: "broken"},
+			{Severity: SeverityWarning, Message: "odd"},
+		}},
+	}
+	tests := []struct {
+		format string
+		want   []string
+	}{
+		{
+			format: FormatText,
+			want: []string{
+				`ns.yaml[1] namespace "team-b": error: a: broken`,
+				`ns.yaml[1] namespace "team-b": warning: odd`,
+				"2 namespaces checked, 1 errors, 1 warnings",
+			},
+		},
+		{
+			format: FormatJSON,
+			want:   []string{`"namespace": "team-b"`, `"severity": "error"`},
+		},
+		{
+			format: FormatJUnit,
+			want:   []string{`tests="2" failures="1"`, `<failure message="1 errors">error: a: broken</failure>`, "warning: odd"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			if err := Write(buf, tt.format, results); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Write() output misses %q:\n%s", want, buf.String())
+				}
+			}
+		})
+	}
+	if err := Write(&bytes.Buffer{}, "yaml", results); err == nil {
+		t.Error("Write() with unknown format error = nil, want error")
+	}
+}
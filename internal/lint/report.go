@@ -0,0 +1,127 @@
+package lint
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatJUnit = "junit"
+)
+
+// Result holds the findings of a single namespace manifest
+type Result struct {
+	Source    string    `json:"source"`
+	Document  int       `json:"document"`
+	Namespace string    `json:"namespace"`
+	Findings  []Finding `json:"findings"`
+}
+
+// Count returns the number of findings with the given severity
+func (r Result) Count(severity Severity) int {
+	n := 0
+	for _, f := range r.Findings {
+		if f.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// Write writes the results in the given format
+func Write(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatText:
+		return writeText(w, results)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	case FormatJUnit:
+		return writeJUnit(w, results)
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+func writeText(w io.Writer, results []Result) error {
+	errs, warnings := 0, 0
+	for _, r := range results {
+		for _, f := range r.Findings {
+			if _, err := fmt.Fprintf(w, "%s[%d] namespace %q: %s\n", r.Source, r.Document, r.Namespace, f); err != nil {
+				return err
+			}
+		}
+		errs += r.Count(SeverityError)
+		warnings += r.Count(SeverityWarning)
+	}
+	_, err := fmt.Fprintf(w, "%d namespaces checked, %d errors, %d warnings\n", len(results), errs, warnings)
+	return err
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// writeJUnit reports every namespace as a test case, which fails if it has errors.
+// Warnings are reported as output of the test case.
+func writeJUnit(w io.Writer, results []Result) error {
+	suite := junitTestSuite{Name: "nspm lint", Tests: len(results)}
+	for _, r := range results {
+		tc := junitTestCase{
+			Name:      fmt.Sprintf("%s[%d]", r.Source, r.Document),
+			ClassName: r.Namespace,
+		}
+		var errs, warnings []string
+		for _, f := range r.Findings {
+			if f.Severity == SeverityError {
+				errs = append(errs, f.String())
+			} else {
+				warnings = append(warnings, f.String())
+			}
+		}
+		if len(errs) > 0 {
+			suite.Failures++
+			tc.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d errors", len(errs)),
+				Text:    strings.Join(errs, "\n"),
+			}
+		}
+		tc.SystemOut = strings.Join(warnings, "\n")
+		suite.Cases = append(suite.Cases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,108 @@
+// Package manifest reads Kubernetes manifests from files, directories and streams.
+package manifest
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// Stdin is the path that reads from the given stdin reader
+const Stdin = "-"
+
+// Namespace is a Namespace read from a manifest
+type Namespace struct {
+	// Source is the file the namespace was read from, or "-" for stdin
+	Source string
+	// Document is the index of the YAML document in the source, starting at 0
+	Document  int
+	Namespace *corev1.Namespace
+}
+
+// ReadNamespaces reads all Namespaces from the given paths. Paths can be files, directories, which are
+// walked for .yaml, .yml and .json files, or Stdin. Documents of other kinds are skipped.
+func ReadNamespaces(paths []string, stdin io.Reader) ([]Namespace, error) {
+	var namespaces []Namespace
+	for _, path := range paths {
+		if path == Stdin {
+			ns, err := Decode(Stdin, stdin)
+			if err != nil {
+				return nil, err
+			}
+			namespaces = append(namespaces, ns...)
+			continue
+		}
+		err := filepath.WalkDir(path, func(file string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// explicitly given files are read regardless of their extension
+			if file != path && !isManifest(file) {
+				return nil
+			}
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			ns, err := Decode(file, f)
+			if err != nil {
+				return err
+			}
+			namespaces = append(namespaces, ns...)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return namespaces, nil
+}
+
+func isManifest(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// Decode reads all Namespaces from a YAML or JSON stream with one or more documents
+func Decode(source string, r io.Reader) ([]Namespace, error) {
+	var namespaces []Namespace
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(r))
+	for doc := 0; ; doc++ {
+		data, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return namespaces, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: unable to read document %d: %w", source, doc, err)
+		}
+		meta := metav1.TypeMeta{}
+		if err := yaml.Unmarshal(data, &meta); err != nil {
+			return nil, fmt.Errorf("%s: invalid document %d: %w", source, doc, err)
+		}
+		if meta.Kind != "Namespace" || (meta.APIVersion != "v1" && meta.APIVersion != "") {
+			continue
+		}
+		ns := &corev1.Namespace{}
+		if err := yaml.UnmarshalStrict(data, ns); err != nil {
+			return nil, fmt.Errorf("%s: invalid namespace in document %d: %w", source, doc, err)
+		}
+		namespaces = append(namespaces, Namespace{Source: source, Document: doc, Namespace: ns})
+	}
+}
@@ -0,0 +1,89 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const stream = `apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-a
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: team-a
+  namespace: team-a
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: team-b
+`
+
+type decoded struct {
+	Source    string
+	Document  int
+	Namespace string
+}
+
+func summarize(namespaces []Namespace) []decoded {
+	var out []decoded
+	for _, ns := range namespaces {
+		out = append(out, decoded{Source: ns.Source, Document: ns.Document, Namespace: ns.Namespace.Name})
+	}
+	return out
+}
+
+func TestDecode(t *testing.T) {
+	got, err := Decode("stream", strings.NewReader(stream))
+	if err != nil {
+		t.Fatalf("Decode() error = %v", err)
+	}
+	want := []decoded{
+		{Source: "stream", Document: 0, Namespace: "team-a"},
+		{Source: "stream", Document: 2, Namespace: "team-b"},
+	}
+	if diff := cmp.Diff(want, summarize(got)); diff != "" {
+		t.Errorf("Decode() mismatch (-want +got):\n%s", diff)
+	}
+
+	if _, err := Decode("typo", strings.NewReader("apiVersion: v1\nkind: Namespace\nmetadata:\n  nmae: typo\n")); err == nil {
+		t.Error("Decode() with unknown field error = nil, want error")
+	}
+}
+
+func TestReadNamespaces(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nested"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"a.yaml":        "apiVersion: v1\nkind: Namespace\nmetadata:\n  name: a\n",
+		"nested/b.json": `{"apiVersion": "v1", "kind": "Namespace", "metadata": {"name": "b"}}`,
+		"README.md":     "not a manifest",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := ReadNamespaces([]string{dir, Stdin}, strings.NewReader("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: c\n"))
+	if err != nil {
+		t.Fatalf("ReadNamespaces() error = %v", err)
+	}
+	want := []decoded{
+		{Source: filepath.Join(dir, "a.yaml"), Namespace: "a"},
+		{Source: filepath.Join(dir, "nested", "b.json"), Namespace: "b"},
+		{Source: Stdin, Namespace: "c"},
+	}
+	if diff := cmp.Diff(want, summarize(got)); diff != "" {
+		t.Errorf("ReadNamespaces() mismatch (-want +got):\n%s", diff)
+	}
+}
@@ -0,0 +1,203 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/cel-go/cel"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	ErrPolicyDenied          = errors.New("denied by policy")
+	ErrInvalidPolicy         = errors.New("invalid policy")
+	ErrPolicyEvaluation      = errors.New("policy evaluation failed")
+	ErrPolicyNonBooleanValue = errors.New("policy expression did not evaluate to a boolean")
+)
+
+const (
+	VarObject   = "object"
+	VarSubjects = "subjects"
+	VarRoleRef  = "roleRef"
+	VarRules    = "rules"
+)
+
+// Policy is a single CEL expression that has to evaluate to true in order to
+// allow the permissions requested by a namespace.
+type Policy struct {
+	// Name identifies the policy in events and logs
+	Name string `json:"name"`
+	// Expression is the CEL expression to evaluate. It has access to the
+	// variables `object` (the namespace), `subjects`, `roleRef` and `rules`.
+	Expression string `json:"expression"`
+	// Message is reported if the expression evaluates to false
+	Message string `json:"message,omitempty"`
+}
+
+// Config is the on-disk representation of a set of policies
+type Config struct {
+	Policies []Policy `json:"policies"`
+}
+
+// Input holds the parsed permission request of a namespace
+type Input struct {
+	Namespace *corev1.Namespace
+	Subjects  []rbacv1.Subject
+	RoleRef   rbacv1.RoleRef
+	Rules     []rbacv1.PolicyRule
+}
+
+type compiledPolicy struct {
+	Policy
+	program cel.Program
+}
+
+// Evaluator evaluates a set of compiled policies against a permission request.
+// A nil Evaluator allows every request.
+type Evaluator struct {
+	policies []compiledPolicy
+}
+
+// LoadFile reads a policy configuration from a YAML or JSON file and compiles it
+//
+// Example file:
+//
+//	policies:
+//	- name: no-secret-deletion-in-feature-namespaces
+//	  expression: |
+//	    !object.metadata.name.startsWith("feature-") ||
+//	    !rules.exists(r, "secrets" in r.resources && "delete" in r.verbs)
+//	  message: feature namespaces may not grant delete on secrets
+func LoadFile(path string) (*Evaluator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := Config{}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPolicy, err)
+	}
+	return New(cfg.Policies)
+}
+
+// New compiles the given policies into an Evaluator
+func New(policies []Policy) (*Evaluator, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(VarObject, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(VarSubjects, cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+		cel.Variable(VarRoleRef, cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable(VarRules, cel.ListType(cel.MapType(cel.StringType, cel.DynType))),
+	)
+	if err != nil {
+		return nil, err
+	}
+	e := &Evaluator{}
+	for idx, p := range policies {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%w at index %d: name must not be empty", ErrInvalidPolicy, idx)
+		}
+		ast, issues := env.Compile(p.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPolicy, p.Name, issues.Err())
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPolicy, p.Name, ErrPolicyNonBooleanValue)
+		}
+		prg, err := env.Program(ast)
+		if err != nil {
+			return nil, fmt.Errorf("%w %q: %w", ErrInvalidPolicy, p.Name, err)
+		}
+		e.policies = append(e.policies, compiledPolicy{Policy: p, program: prg})
+	}
+	return e, nil
+}
+
+// Evaluate runs all policies against the given input. It returns an error
+// wrapping ErrPolicyDenied for the first policy that evaluates to false.
+func (e *Evaluator) Evaluate(in Input) error {
+	if e == nil || len(e.policies) == 0 {
+		return nil
+	}
+	vars, err := activation(in)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrPolicyEvaluation, err)
+	}
+	for _, p := range e.policies {
+		out, _, err := p.program.Eval(vars)
+		if err != nil {
+			return fmt.Errorf("%w for policy %q: %w", ErrPolicyEvaluation, p.Name, err)
+		}
+		allowed, ok := out.Value().(bool)
+		if !ok {
+			return fmt.Errorf("%w for policy %q: %w", ErrPolicyEvaluation, p.Name, ErrPolicyNonBooleanValue)
+		}
+		if !allowed {
+			msg := p.Message
+			if msg == "" {
+				msg = fmt.Sprintf("expression %q evaluated to false", p.Expression)
+			}
+			return fmt.Errorf("%w %q: %s", ErrPolicyDenied, p.Name, msg)
+		}
+	}
+	return nil
+}
+
+// Len returns the amount of configured policies
+func (e *Evaluator) Len() int {
+	if e == nil {
+		return 0
+	}
+	return len(e.policies)
+}
+
+func activation(in Input) (map[string]any, error) {
+	ns := map[string]any{}
+	if in.Namespace != nil {
+		var err error
+		ns, err = runtime.DefaultUnstructuredConverter.ToUnstructured(in.Namespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	subjects := make([]map[string]any, 0, len(in.Subjects))
+	for _, s := range in.Subjects {
+		subjects = append(subjects, map[string]any{
+			"kind":      s.Kind,
+			"apiGroup":  s.APIGroup,
+			"name":      s.Name,
+			"namespace": s.Namespace,
+		})
+	}
+	rules := make([]map[string]any, 0, len(in.Rules))
+	for _, r := range in.Rules {
+		rules = append(rules, map[string]any{
+			"verbs":           nonNil(r.Verbs),
+			"apiGroups":       nonNil(r.APIGroups),
+			"resources":       nonNil(r.Resources),
+			"resourceNames":   nonNil(r.ResourceNames),
+			"nonResourceURLs": nonNil(r.NonResourceURLs),
+		})
+	}
+	return map[string]any{
+		VarObject:   ns,
+		VarSubjects: subjects,
+		VarRoleRef: map[string]any{
+			"kind":     in.RoleRef.Kind,
+			"apiGroup": in.RoleRef.APIGroup,
+			"name":     in.RoleRef.Name,
+		},
+		VarRules: rules,
+	}, nil
+}
+
+// nonNil makes sure expressions can access list fields without checking for their presence
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
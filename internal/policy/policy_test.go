@@ -0,0 +1,163 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNew(t *testing.T) {
+	type args struct {
+		policies []Policy
+	}
+	tests := []struct {
+		name    string
+		args    args
+		wantErr bool
+	}{
+		{
+			name: "valid expression",
+			args: args{policies: []Policy{
+				{Name: "only-team-groups", Expression: `subjects.all(s, s.kind != "Group" || s.name.startsWith("team-"))`},
+			}},
+			wantErr: false,
+		},
+		{
+			name: "missing name",
+			args: args{policies: []Policy{
+				{Expression: `true`},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "syntax error",
+			args: args{policies: []Policy{
+				{Name: "broken", Expression: `subjects.all(s,`},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "non boolean expression",
+			args: args{policies: []Policy{
+				{Name: "string", Expression: `"foo"`},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "unknown variable",
+			args: args{policies: []Policy{
+				{Name: "unknown", Expression: `foo == "bar"`},
+			}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.args.policies)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("New() error = %v, want %v", err, ErrInvalidPolicy)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Evaluate(t *testing.T) {
+	policies := []Policy{
+		{
+			Name:       "no-secret-deletion-in-feature-namespaces",
+			Expression: `!object.metadata.name.startsWith("feature-") || !rules.exists(r, "secrets" in r.resources && "delete" in r.verbs)`,
+			Message:    "feature namespaces may not grant delete on secrets",
+		},
+		{
+			Name:       "only-team-groups",
+			Expression: `subjects.all(s, s.kind != "Group" || s.name.startsWith("team-"))`,
+		},
+		{
+			Name:       "no-cluster-admin",
+			Expression: `!(roleRef.kind == "ClusterRole" && roleRef.name == "cluster-admin")`,
+		},
+	}
+	e, err := New(policies)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		in      Input
+		wantErr error
+	}{
+		{
+			name: "allowed",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "feature-foo"}},
+				Subjects:  []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}},
+				RoleRef:   rbacv1.RoleRef{Kind: "Role", APIGroup: "rbac.authorization.k8s.io", Name: "feature-foo"},
+				Rules:     []rbacv1.PolicyRule{{Verbs: []string{"get"}, Resources: []string{"secrets"}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "delete on secrets in feature namespace",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "feature-foo"}},
+				Subjects:  []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}},
+				Rules:     []rbacv1.PolicyRule{{Verbs: []string{"get", "delete"}, Resources: []string{"secrets"}}},
+			},
+			wantErr: ErrPolicyDenied,
+		},
+		{
+			name: "delete on secrets in other namespace",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+				Subjects:  []rbacv1.Subject{{Kind: "Group", Name: "team-foo"}},
+				Rules:     []rbacv1.PolicyRule{{Verbs: []string{"delete"}, Resources: []string{"secrets"}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "rule without resources",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "feature-foo"}},
+				Rules:     []rbacv1.PolicyRule{{Verbs: []string{"delete"}}},
+			},
+			wantErr: nil,
+		},
+		{
+			name: "group without team prefix",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+				Subjects:  []rbacv1.Subject{{Kind: "Group", Name: "developers"}},
+			},
+			wantErr: ErrPolicyDenied,
+		},
+		{
+			name: "cluster admin",
+			in: Input{
+				Namespace: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+				RoleRef:   rbacv1.RoleRef{Kind: "ClusterRole", APIGroup: "rbac.authorization.k8s.io", Name: "cluster-admin"},
+			},
+			wantErr: ErrPolicyDenied,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := e.Evaluate(tt.in)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Evaluator.Evaluate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEvaluator_EvaluateNil(t *testing.T) {
+	var e *Evaluator
+	if err := e.Evaluate(Input{}); err != nil {
+		t.Errorf("Evaluator.Evaluate() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,209 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+const (
+	// LabelShardGroup is set on the Leases of all members of a shard group
+	LabelShardGroup = "ns.tagesspiegel.de/shard-group"
+
+	// DefaultGroup is the shard group of the controller replicas
+	DefaultGroup = "namespace-permission-manager"
+)
+
+var (
+	ErrNotSynced = errors.New("shard membership is not synced")
+)
+
+var _ manager.LeaderElectionRunnable = &LeaseMembership{}
+
+// LeaseMembership shards the namespaces across all live replicas. Every replica renews a Lease
+// in Namespace and owns the namespaces whose hash matches its position among the live Leases.
+// When replicas come and go, the namespaces are rebalanced across the remaining ones.
+type LeaseMembership struct {
+	// Client writes the Lease of the replica
+	Client client.Client
+	// Reader lists the Leases of all replicas. It should read from the API server directly,
+	// so the controller doesn't need to cache all Leases of the cluster.
+	Reader    client.Reader
+	Namespace string
+	Group     string
+	// Identity identifies the replica, e.g. the name of its pod
+	Identity string
+	// LeaseDuration is the time after which a replica that stopped renewing its Lease is considered gone
+	LeaseDuration time.Duration
+
+	mu      sync.RWMutex
+	members []string
+	// synced is the time of the last successful sync
+	synced  time.Time
+	changed chan struct{}
+	now     func() time.Time
+}
+
+// NewLeaseMembership returns the membership of the replica with the given identity in the default group
+func NewLeaseMembership(c client.Client, reader client.Reader, namespace, identity string, leaseDuration time.Duration) *LeaseMembership {
+	return &LeaseMembership{
+		Client:        c,
+		Reader:        reader,
+		Namespace:     namespace,
+		Group:         DefaultGroup,
+		Identity:      identity,
+		LeaseDuration: leaseDuration,
+		changed:       make(chan struct{}, 1),
+		now:           time.Now,
+	}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable, all replicas take part
+func (m *LeaseMembership) NeedLeaderElection() bool {
+	return false
+}
+
+// Start renews the Lease of the replica until the context is cancelled and then releases it
+func (m *LeaseMembership) Start(ctx context.Context) error {
+	logx := logf.Log.WithName("shard").WithValues("identity", m.Identity)
+	ticker := time.NewTicker(m.LeaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		if err := m.Sync(ctx); err != nil {
+			logx.Error(err, "unable to sync shard membership")
+		}
+		select {
+		case <-ctx.Done():
+			// let the other replicas take over right away
+			releaseCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := m.Client.Delete(releaseCtx, m.lease()); client.IgnoreNotFound(err) != nil {
+				logx.Error(err, "unable to release shard lease")
+			}
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sync renews the Lease of the replica and updates the members from the live Leases
+func (m *LeaseMembership) Sync(ctx context.Context) error {
+	now := m.now()
+	if err := m.renew(ctx, now); err != nil {
+		return err
+	}
+	leases := &coordinationv1.LeaseList{}
+	if err := m.Reader.List(ctx, leases, client.InNamespace(m.Namespace), client.MatchingLabels{LabelShardGroup: m.Group}); err != nil {
+		return err
+	}
+	members := []string{m.Identity}
+	for _, l := range leases.Items {
+		if l.Spec.HolderIdentity == nil || *l.Spec.HolderIdentity == m.Identity || !live(l, now) {
+			continue
+		}
+		members = append(members, *l.Spec.HolderIdentity)
+	}
+	slices.Sort(members)
+	members = slices.Compact(members)
+
+	m.mu.Lock()
+	changed := !slices.Equal(m.members, members)
+	m.members = members
+	m.synced = now
+	m.mu.Unlock()
+	if changed {
+		logf.Log.WithName("shard").Info("shard members changed", "identity", m.Identity,
+			"members", members, "shard", slices.Index(members, m.Identity), "shards", len(members))
+		select {
+		case m.changed <- struct{}{}:
+		default:
+			// a rebalance is already pending
+		}
+	}
+	return nil
+}
+
+// Owns reports whether the namespace belongs to the replica. Nothing is owned before the first sync.
+func (m *LeaseMembership) Owns(namespace string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	id := slices.Index(m.members, m.Identity)
+	if id < 0 {
+		return false
+	}
+	return Index(namespace, len(m.members)) == id
+}
+
+// Check implements healthz.Checker. It fails until the first successful sync, as nothing is owned before,
+// and once the last successful sync is older than the LeaseDuration, as the other replicas consider
+// the replica gone and took over its namespaces.
+func (m *LeaseMembership) Check(_ *http.Request) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.synced.IsZero() {
+		return fmt.Errorf("%w: no successful sync yet", ErrNotSynced)
+	}
+	if since := m.now().Sub(m.synced); since > m.LeaseDuration {
+		return fmt.Errorf("%w: last successful sync %s ago", ErrNotSynced, since.Round(time.Second))
+	}
+	return nil
+}
+
+// Changed is signalled whenever the members change and the namespaces have to be rebalanced
+func (m *LeaseMembership) Changed() <-chan struct{} {
+	return m.changed
+}
+
+// renew creates or renews the Lease of the replica
+func (m *LeaseMembership) renew(ctx context.Context, now time.Time) error {
+	lease := &coordinationv1.Lease{}
+	err := m.Reader.Get(ctx, client.ObjectKeyFromObject(m.lease()), lease)
+	if apierrors.IsNotFound(err) {
+		lease = m.lease()
+		lease.Spec = coordinationv1.LeaseSpec{
+			HolderIdentity:       ptr.To(m.Identity),
+			LeaseDurationSeconds: ptr.To(int32(m.LeaseDuration.Seconds())),
+			AcquireTime:          &metav1.MicroTime{Time: now},
+			RenewTime:            &metav1.MicroTime{Time: now},
+		}
+		return m.Client.Create(ctx, lease)
+	}
+	if err != nil {
+		return err
+	}
+	lease.Spec.HolderIdentity = ptr.To(m.Identity)
+	lease.Spec.LeaseDurationSeconds = ptr.To(int32(m.LeaseDuration.Seconds()))
+	lease.Spec.RenewTime = &metav1.MicroTime{Time: now}
+	return m.Client.Update(ctx, lease)
+}
+
+// lease returns the Lease of the replica without its spec
+func (m *LeaseMembership) lease() *coordinationv1.Lease {
+	return &coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.Group + "-" + m.Identity,
+			Namespace: m.Namespace,
+			Labels:    map[string]string{LabelShardGroup: m.Group},
+		},
+	}
+}
+
+// live reports whether the holder of the Lease renewed it within its duration
+func live(l coordinationv1.Lease, now time.Time) bool {
+	if l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+		return false
+	}
+	return now.Before(l.Spec.RenewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second))
+}
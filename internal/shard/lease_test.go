@@ -0,0 +1,95 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLeaseMembership(t *testing.T) {
+	ctx := context.Background()
+	c := fake.NewClientBuilder().WithScheme(scheme.Scheme).Build()
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	replica := func(identity string) *LeaseMembership {
+		m := NewLeaseMembership(c, c, "system", identity, 15*time.Second)
+		m.now = func() time.Time { return now }
+		return m
+	}
+	a, b := replica("a"), replica("b")
+
+	sync := func(ms ...*LeaseMembership) {
+		t.Helper()
+		for _, m := range ms {
+			if err := m.Sync(ctx); err != nil {
+				t.Fatalf("Sync() error = %v", err)
+			}
+		}
+	}
+	owners := func(namespace string, ms ...*LeaseMembership) []string {
+		var got []string
+		for _, m := range ms {
+			if m.Owns(namespace) {
+				got = append(got, m.Identity)
+			}
+		}
+		return got
+	}
+
+	if a.Owns("team") {
+		t.Errorf("Owns() before the first sync = true, want false")
+	}
+	if err := a.Check(nil); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("Check() before the first sync error = %v, want %v", err, ErrNotSynced)
+	}
+
+	// both replicas join, every namespace is owned by exactly one of them
+	sync(a, b, a)
+	if err := a.Check(nil); err != nil {
+		t.Errorf("Check() after sync error = %v, want nil", err)
+	}
+	if diff := cmp.Diff([]string{"a", "b"}, a.members); diff != "" {
+		t.Errorf("members mismatch (-want +got):\n%s", diff)
+	}
+	for i := 0; i < 100; i++ {
+		namespace := fmt.Sprintf("feature-%d", i)
+		if got := owners(namespace, a, b); len(got) != 1 {
+			t.Fatalf("namespace %q is owned by %v, want a single replica", namespace, got)
+		}
+	}
+	select {
+	case <-a.Changed():
+	default:
+		t.Errorf("Changed() not signalled after the members changed")
+	}
+
+	// b stops renewing its lease, a takes over all namespaces
+	now = now.Add(time.Minute)
+	sync(a)
+	if diff := cmp.Diff([]string{"a"}, a.members); diff != "" {
+		t.Errorf("members after expiry mismatch (-want +got):\n%s", diff)
+	}
+	if err := b.Check(nil); !errors.Is(err, ErrNotSynced) {
+		t.Errorf("Check() of replica that stopped syncing error = %v, want %v", err, ErrNotSynced)
+	}
+	for i := 0; i < 100; i++ {
+		if namespace := fmt.Sprintf("feature-%d", i); !a.Owns(namespace) {
+			t.Fatalf("namespace %q is not owned by the only live replica", namespace)
+		}
+	}
+
+	leases := &coordinationv1.LeaseList{}
+	if err := c.List(ctx, leases, client.MatchingLabels{LabelShardGroup: DefaultGroup}); err != nil {
+		t.Fatal(err)
+	}
+	if len(leases.Items) != 2 {
+		t.Errorf("got %d leases, want 2", len(leases.Items))
+	}
+}
@@ -0,0 +1,43 @@
+// Package shard distributes namespaces across controller replicas by the hash of their name.
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+var (
+	ErrInvalidShard = errors.New("invalid shard")
+)
+
+// Index returns the shard out of count shards the namespace belongs to
+func Index(namespace string, count int) int {
+	h := fnv.New32a()
+	h.Write([]byte(namespace))
+	return int(h.Sum32() % uint32(count))
+}
+
+// Static is a fixed shard assigned to a replica through flags
+type Static struct {
+	ID    int
+	Count int
+}
+
+// NewStatic returns the shard id out of count shards
+func NewStatic(id, count int) (*Static, error) {
+	if count < 1 || id < 0 || id >= count {
+		return nil, fmt.Errorf("%w: id %d of %d shards, expected 0 <= id < count", ErrInvalidShard, id, count)
+	}
+	return &Static{ID: id, Count: count}, nil
+}
+
+// Owns reports whether the namespace belongs to the shard
+func (s *Static) Owns(namespace string) bool {
+	return Index(namespace, s.Count) == s.ID
+}
+
+// Changed never fires, as a static shard never changes
+func (s *Static) Changed() <-chan struct{} {
+	return nil
+}
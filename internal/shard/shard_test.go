@@ -0,0 +1,53 @@
+package shard
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestNewStatic(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      int
+		count   int
+		wantErr error
+	}{
+		{name: "single shard", id: 0, count: 1},
+		{name: "last shard", id: 2, count: 3},
+		{name: "id out of range", id: 3, count: 3, wantErr: ErrInvalidShard},
+		{name: "negative id", id: -1, count: 3, wantErr: ErrInvalidShard},
+		{name: "no shards", id: 0, count: 0, wantErr: ErrInvalidShard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewStatic(tt.id, tt.count)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NewStatic() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStatic_Owns(t *testing.T) {
+	const count = 3
+	owned := make([]int, count)
+	for i := 0; i < 300; i++ {
+		namespace := fmt.Sprintf("feature-%d", i)
+		owners := 0
+		for id := 0; id < count; id++ {
+			if (&Static{ID: id, Count: count}).Owns(namespace) {
+				owners++
+				owned[id]++
+			}
+		}
+		if owners != 1 {
+			t.Fatalf("namespace %q is owned by %d shards, want 1", namespace, owners)
+		}
+	}
+	for id, n := range owned {
+		if n == 0 {
+			t.Errorf("shard %d owns no namespace", id)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package tracing sets up OpenTelemetry tracing for the controller.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// ExporterNone disables tracing
+	ExporterNone = "none"
+	// ExporterOTLPGRPC exports spans to an OTLP endpoint using gRPC
+	ExporterOTLPGRPC = "otlp-grpc"
+	// ExporterOTLPHTTP exports spans to an OTLP endpoint using HTTP
+	ExporterOTLPHTTP = "otlp-http"
+	// ExporterStdout writes spans as JSON to stdout
+	ExporterStdout = "stdout"
+	// ExporterFile writes spans as JSON to a file
+	ExporterFile = "file"
+
+	// ServiceName is reported as service.name resource attribute
+	ServiceName = "namespace-permission-manager"
+
+	instrumentationName = "github.com/tagesspiegel/kubernetes-namespace-permission-manager"
+)
+
+var (
+	ErrUnknownExporter = errors.New("unknown tracing exporter")
+)
+
+// Options configure the exporter of the spans
+type Options struct {
+	// Exporter is one of the Exporter* constants. An empty value disables tracing.
+	Exporter string
+	// Endpoint is the OTLP endpoint. If empty, the OTEL_EXPORTER_OTLP_* environment variables are honoured.
+	Endpoint string
+	// Insecure disables TLS for the OTLP exporters
+	Insecure bool
+	// File is the path spans are appended to by the file exporter
+	File string
+	// SampleRatio is the fraction of traces to sample, between 0 and 1
+	SampleRatio float64
+}
+
+// Setup installs a global tracer provider exporting spans as configured and returns a function flushing
+// and stopping it. If tracing is disabled, the global no-op provider is kept.
+func Setup(ctx context.Context, opts Options) (func(context.Context) error, error) {
+	exporter, closer, err := newExporter(ctx, opts)
+	if err != nil || exporter == nil {
+		return func(context.Context) error { return nil }, err
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName(ServiceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(opts.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return func(ctx context.Context) error {
+		return errors.Join(provider.Shutdown(ctx), closer.Close())
+	}, nil
+}
+
+func newExporter(ctx context.Context, opts Options) (sdktrace.SpanExporter, io.Closer, error) {
+	switch opts.Exporter {
+	case "", ExporterNone:
+		return nil, nil, nil
+	case ExporterOTLPGRPC:
+		var o []otlptracegrpc.Option
+		if opts.Endpoint != "" {
+			o = append(o, otlptracegrpc.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			o = append(o, otlptracegrpc.WithInsecure())
+		}
+		exp, err := otlptracegrpc.New(ctx, o...)
+		return exp, io.NopCloser(nil), err
+	case ExporterOTLPHTTP:
+		var o []otlptracehttp.Option
+		if opts.Endpoint != "" {
+			o = append(o, otlptracehttp.WithEndpoint(opts.Endpoint))
+		}
+		if opts.Insecure {
+			o = append(o, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, o...)
+		return exp, io.NopCloser(nil), err
+	case ExporterStdout:
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+		return exp, io.NopCloser(nil), err
+	case ExporterFile:
+		f, err := os.OpenFile(opts.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to open trace file: %w", err)
+		}
+		exp, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		return exp, f, err
+	}
+	return nil, nil, fmt.Errorf("%w: %q", ErrUnknownExporter, opts.Exporter)
+}
+
+// Tracer returns the tracer used to instrument the controller
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// End records the error on the span, if any, and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "traces.json")
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr error
+	}{
+		{
+			name: "disabled",
+			opts: Options{},
+		},
+		{
+			name: "none",
+			opts: Options{Exporter: ExporterNone},
+		},
+		{
+			name: "file",
+			opts: Options{Exporter: ExporterFile, File: file, SampleRatio: 1},
+		},
+		{
+			name:    "unknown exporter",
+			opts:    Options{Exporter: "jaeger"},
+			wantErr: ErrUnknownExporter,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shutdown, err := Setup(context.Background(), tt.opts)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Setup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			_, span := Tracer().Start(context.Background(), "test")
+			End(span, errors.New("failed"))
+			if err := shutdown(context.Background()); err != nil {
+				t.Errorf("shutdown() error = %v", err)
+			}
+		})
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 {
+		t.Error("Setup() with file exporter wrote no spans")
+	}
+}
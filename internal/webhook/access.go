@@ -0,0 +1,145 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var (
+	ErrEscalation = errors.New("requester does not hold the permissions to grant")
+)
+
+// accessReviewer checks the permissions of a user using SubjectAccessReviews
+type accessReviewer struct {
+	client client.Client
+	// reader is used to look up referenced roles
+	reader client.Reader
+}
+
+// allowed reports whether the user is allowed to perform the given action
+func (a *accessReviewer) allowed(ctx context.Context, user authenticationv1.UserInfo, attrs authorizationv1.ResourceAttributes) (bool, error) {
+	extra := map[string]authorizationv1.ExtraValue{}
+	for k, v := range user.Extra {
+		extra[k] = authorizationv1.ExtraValue(v)
+	}
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			ResourceAttributes: &attrs,
+			User:               user.Username,
+			UID:                user.UID,
+			Groups:             user.Groups,
+			Extra:              extra,
+		},
+	}
+	if err := a.client.Create(ctx, sar); err != nil {
+		return false, fmt.Errorf("unable to create subject access review: %w", err)
+	}
+	return sar.Status.Allowed, nil
+}
+
+// checkCustomRules makes sure the user may grant the custom rules of the namespace. This is the case if the user
+// holds the `bind` verb on the Role the controller creates for the rules, or every permission granted by them.
+// The `escalate` verb isn't enough, as it only allows writing Roles, not binding them to any subject.
+func (a *accessReviewer) checkCustomRules(ctx context.Context, user authenticationv1.UserInfo, namespace string, rules []rbacv1.PolicyRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	ok, err := a.allowed(ctx, user, authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "bind",
+		Group:     rbacv1.GroupName,
+		Resource:  "roles",
+		Name:      namespace,
+	})
+	if err != nil || ok {
+		return err
+	}
+	return a.checkRules(ctx, user, namespace, rules)
+}
+
+// checkRules makes sure the user holds every permission granted by the rules in the given namespace
+func (a *accessReviewer) checkRules(ctx context.Context, user authenticationv1.UserInfo, namespace string, rules []rbacv1.PolicyRule) error {
+	for _, rule := range rules {
+		resourceNames := rule.ResourceNames
+		if len(resourceNames) == 0 {
+			resourceNames = []string{""}
+		}
+		for _, verb := range rule.Verbs {
+			for _, group := range rule.APIGroups {
+				for _, resource := range rule.Resources {
+					res, sub, _ := strings.Cut(resource, "/")
+					for _, name := range resourceNames {
+						ok, err := a.allowed(ctx, user, authorizationv1.ResourceAttributes{
+							Namespace:   namespace,
+							Verb:        verb,
+							Group:       group,
+							Resource:    res,
+							Subresource: sub,
+							Name:        name,
+						})
+						if err != nil {
+							return err
+						}
+						if !ok {
+							return fmt.Errorf("%w: verb=%s;apiGroup=%s;resource=%s;resourceName=%s", ErrEscalation, verb, group, resource, name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// checkRoleRef makes sure the user is allowed to bind the referenced role in the given namespace.
+// This is the case if the user holds the `bind` verb on the role or all of its permissions.
+// A role that doesn't exist yet can only be bound with the `bind` verb, as its permissions are unknown.
+func (a *accessReviewer) checkRoleRef(ctx context.Context, user authenticationv1.UserInfo, namespace string, roleRef rbacv1.RoleRef) error {
+	attrs := authorizationv1.ResourceAttributes{
+		Namespace: namespace,
+		Verb:      "bind",
+		Group:     rbacv1.GroupName,
+		Name:      roleRef.Name,
+	}
+	var (
+		rules []rbacv1.PolicyRule
+		err   error
+	)
+	switch roleRef.Kind {
+	case "ClusterRole":
+		attrs.Resource = "clusterroles"
+		cr := &rbacv1.ClusterRole{}
+		err = a.reader.Get(ctx, client.ObjectKey{Name: roleRef.Name}, cr)
+		rules = cr.Rules
+	case "Role":
+		attrs.Resource = "roles"
+		role := &rbacv1.Role{}
+		err = a.reader.Get(ctx, client.ObjectKey{Namespace: namespace, Name: roleRef.Name}, role)
+		rules = role.Rules
+	default:
+		return fmt.Errorf("unsupported role ref kind %q", roleRef.Kind)
+	}
+	missing := apierrors.IsNotFound(err)
+	if err != nil && !missing {
+		return err
+	}
+	ok, err := a.allowed(ctx, user, attrs)
+	if err != nil || ok {
+		return err
+	}
+	if missing {
+		return fmt.Errorf("unable to bind %s %q: %w: the role does not exist", roleRef.Kind, roleRef.Name, ErrEscalation)
+	}
+	if err := a.checkRules(ctx, user, namespace, rules); err != nil {
+		return fmt.Errorf("unable to bind %s %q: %w", roleRef.Kind, roleRef.Name, err)
+	}
+	return nil
+}
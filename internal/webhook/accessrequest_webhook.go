@@ -0,0 +1,154 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+var accessrequestlog = logf.Log.WithName("accessrequest-webhook")
+
+var (
+	_ admission.CustomValidator = &AccessRequestValidator{}
+)
+
+var (
+	ErrInvalidAccessRequest = errors.New("invalid access request")
+	ErrNotAnApprover        = errors.New("requester is not allowed to approve access requests")
+	ErrImmutableRequest     = errors.New("access request is immutable")
+)
+
+// AccessRequestValidator makes sure AccessRequests are only approved by members of the approver groups
+// and can't be changed after they were created, except for recording the approval once.
+// It is only registered by config/components/access-requests, as the handler is only served with access requests enabled.
+type AccessRequestValidator struct {
+	// ApproverGroups are the groups whose members may approve or deny access requests
+	ApproverGroups []string
+	// MaxDuration caps the duration of every request. Zero means no limit.
+	MaxDuration time.Duration
+	// Guard protects namespaces and subjects from ever being managed
+	Guard *controller.Guard
+	// Client creates the SubjectAccessReviews checking that the approver may bind the requested role
+	Client client.Client
+	// APIReader is used to look up the requested role without caching all roles of the cluster
+	APIReader client.Reader
+}
+
+// SetupWebhookWithManager registers the webhook for AccessRequests in the manager
+func (v *AccessRequestValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&nsv1alpha1.AccessRequest{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *AccessRequestValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ar, ok := obj.(*nsv1alpha1.AccessRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected an AccessRequest but got a %T", obj)
+	}
+	if ar.Spec.Approval != nil {
+		return nil, fmt.Errorf("%w: approval can't be set on creation", ErrInvalidAccessRequest)
+	}
+	if ar.Spec.Duration.Duration <= 0 {
+		return nil, fmt.Errorf("%w: duration has to be positive", ErrInvalidAccessRequest)
+	}
+	if v.MaxDuration > 0 && ar.Spec.Duration.Duration > v.MaxDuration {
+		return nil, fmt.Errorf("%w: duration exceeds the maximum of %s", ErrInvalidAccessRequest, v.MaxDuration)
+	}
+	switch ar.Spec.RoleRef.Kind {
+	case "Role", "ClusterRole":
+	default:
+		return nil, fmt.Errorf("%w: unsupported role ref kind %q", ErrInvalidAccessRequest, ar.Spec.RoleRef.Kind)
+	}
+	if v.Guard.IsProtectedNamespace(ar.Namespace) {
+		return nil, fmt.Errorf("%w: %q", controller.ErrProtectedNamespace, ar.Namespace)
+	}
+	return nil, v.Guard.CheckSubjects([]rbacv1.Subject{ar.Spec.Subject})
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *AccessRequestValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldAr, ok := oldObj.(*nsv1alpha1.AccessRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected an AccessRequest but got a %T", oldObj)
+	}
+	ar, ok := newObj.(*nsv1alpha1.AccessRequest)
+	if !ok {
+		return nil, fmt.Errorf("expected an AccessRequest but got a %T", newObj)
+	}
+
+	// everything but the approval is immutable
+	oldSpec, spec := oldAr.Spec.DeepCopy(), ar.Spec.DeepCopy()
+	oldSpec.Approval, spec.Approval = nil, nil
+	if !equality.Semantic.DeepEqual(oldSpec, spec) {
+		return nil, fmt.Errorf("%w: only the approval can be changed", ErrImmutableRequest)
+	}
+	if equality.Semantic.DeepEqual(oldAr.Spec.Approval, ar.Spec.Approval) {
+		return nil, nil
+	}
+	if oldAr.Spec.Approval != nil {
+		return nil, fmt.Errorf("%w: the approval was already recorded", ErrImmutableRequest)
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	user := req.UserInfo
+	if !slices.ContainsFunc(user.Groups, func(g string) bool { return slices.Contains(v.ApproverGroups, g) }) {
+		return nil, fmt.Errorf("%w: %q is not a member of the approver groups", ErrNotAnApprover, user.Username)
+	}
+	if ar.Spec.Approval.Approver != user.Username {
+		return nil, fmt.Errorf("%w: approver has to be %q", ErrInvalidAccessRequest, user.Username)
+	}
+	if isRequester(ar.Spec.Subject, ar.Namespace, user) {
+		return nil, fmt.Errorf("%w: %q can't approve their own request", ErrNotAnApprover, user.Username)
+	}
+	// the controller binds the role with its own permissions, so the approver has to be allowed to bind it
+	if ar.Spec.Approval.Approved {
+		reviewer := &accessReviewer{client: v.Client, reader: v.APIReader}
+		if err := reviewer.checkRoleRef(ctx, user, ar.Namespace, ar.Spec.RoleRef); err != nil {
+			return nil, err
+		}
+	}
+	accessrequestlog.Info("access request decided", "namespace", ar.Namespace, "name", ar.Name,
+		"approver", user.Username, "approved", ar.Spec.Approval.Approved)
+	return nil, nil
+}
+
+// isRequester reports whether the user is the subject of the request or a member of it
+func isRequester(subject rbacv1.Subject, namespace string, user authenticationv1.UserInfo) bool {
+	switch subject.Kind {
+	case rbacv1.UserKind:
+		return subject.Name == user.Username
+	case rbacv1.GroupKind:
+		return slices.Contains(user.Groups, subject.Name)
+	case rbacv1.ServiceAccountKind:
+		if subject.Namespace != "" {
+			namespace = subject.Namespace
+		}
+		return "system:serviceaccount:"+namespace+":"+subject.Name == user.Username
+	}
+	return false
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *AccessRequestValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
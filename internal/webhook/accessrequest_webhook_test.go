@@ -0,0 +1,239 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+func accessRequest(mutate func(ar *nsv1alpha1.AccessRequest)) *nsv1alpha1.AccessRequest {
+	ar := &nsv1alpha1.AccessRequest{
+		ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "test"},
+		Spec: nsv1alpha1.AccessRequestSpec{
+			Subject:  rbacv1.Subject{Kind: rbacv1.UserKind, Name: "john", APIGroup: rbacv1.GroupName},
+			RoleRef:  rbacv1.RoleRef{Kind: "ClusterRole", Name: "edit", APIGroup: rbacv1.GroupName},
+			Duration: metav1.Duration{Duration: time.Hour},
+		},
+	}
+	if mutate != nil {
+		mutate(ar)
+	}
+	return ar
+}
+
+func approvalContext(user string, groups ...string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: user, Groups: groups},
+		},
+	})
+}
+
+func TestAccessRequestValidator_ValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		obj     *nsv1alpha1.AccessRequest
+		wantErr error
+	}{
+		{
+			name:    "valid request",
+			obj:     accessRequest(nil),
+			wantErr: nil,
+		},
+		{
+			name: "approval set on creation",
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Approval = &nsv1alpha1.AccessRequestApproval{Approved: true, Approver: "john"}
+			}),
+			wantErr: ErrInvalidAccessRequest,
+		},
+		{
+			name:    "duration exceeds maximum",
+			obj:     accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.Duration.Duration = 48 * time.Hour }),
+			wantErr: ErrInvalidAccessRequest,
+		},
+		{
+			name:    "zero duration",
+			obj:     accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.Duration.Duration = 0 }),
+			wantErr: ErrInvalidAccessRequest,
+		},
+		{
+			name:    "invalid role ref kind",
+			obj:     accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.RoleRef.Kind = "Namespace" }),
+			wantErr: ErrInvalidAccessRequest,
+		},
+		{
+			name:    "protected namespace",
+			obj:     accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Namespace = "kube-system" }),
+			wantErr: controller.ErrProtectedNamespace,
+		},
+		{
+			name: "denied subject",
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Subject = rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "system:masters", APIGroup: rbacv1.GroupName}
+			}),
+			wantErr: controller.ErrDeniedSubject,
+		},
+	}
+	guard, err := controller.NewGuard([]string{"kube-*"}, "kind=Group;name=system:masters")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := &AccessRequestValidator{MaxDuration: 24 * time.Hour, Guard: guard}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateCreate(context.Background(), tt.obj)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AccessRequestValidator.ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAccessRequestValidator_ValidateUpdate(t *testing.T) {
+	approve := func(approver string) func(ar *nsv1alpha1.AccessRequest) {
+		return func(ar *nsv1alpha1.AccessRequest) {
+			ar.Spec.Approval = &nsv1alpha1.AccessRequestApproval{Approved: true, Approver: approver}
+		}
+	}
+	tests := []struct {
+		name    string
+		old     *nsv1alpha1.AccessRequest
+		obj     *nsv1alpha1.AccessRequest
+		ctx     context.Context
+		wantErr error
+	}{
+		{
+			name:    "approver approves",
+			old:     accessRequest(nil),
+			obj:     accessRequest(approve("jane")),
+			ctx:     approvalContext("jane", "approvers"),
+			wantErr: nil,
+		},
+		{
+			name:    "non approver approves",
+			old:     accessRequest(nil),
+			obj:     accessRequest(approve("jane")),
+			ctx:     approvalContext("jane", "developers"),
+			wantErr: ErrNotAnApprover,
+		},
+		{
+			name:    "approver impersonates someone else",
+			old:     accessRequest(nil),
+			obj:     accessRequest(approve("bob")),
+			ctx:     approvalContext("jane", "approvers"),
+			wantErr: ErrInvalidAccessRequest,
+		},
+		{
+			name:    "self approval",
+			old:     accessRequest(nil),
+			obj:     accessRequest(approve("john")),
+			ctx:     approvalContext("john", "approvers"),
+			wantErr: ErrNotAnApprover,
+		},
+		{
+			name: "self approval through group",
+			old: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Subject = rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "oncall", APIGroup: rbacv1.GroupName}
+			}),
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Subject = rbacv1.Subject{Kind: rbacv1.GroupKind, Name: "oncall", APIGroup: rbacv1.GroupName}
+				approve("jane")(ar)
+			}),
+			ctx:     approvalContext("jane", "approvers", "oncall"),
+			wantErr: ErrNotAnApprover,
+		},
+		{
+			name: "self approval of service account",
+			old: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Subject = rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci"}
+			}),
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.Subject = rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "ci"}
+				approve("system:serviceaccount:test:ci")(ar)
+			}),
+			ctx:     approvalContext("system:serviceaccount:test:ci", "approvers"),
+			wantErr: ErrNotAnApprover,
+		},
+		{
+			name: "approver can't bind requested role",
+			old:  accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.RoleRef.Name = "cluster-admin" }),
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.RoleRef.Name = "cluster-admin"
+				approve("jane")(ar)
+			}),
+			ctx:     approvalContext("jane", "approvers"),
+			wantErr: ErrEscalation,
+		},
+		{
+			name: "approver denies role they can't bind",
+			old:  accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.RoleRef.Name = "cluster-admin" }),
+			obj: accessRequest(func(ar *nsv1alpha1.AccessRequest) {
+				ar.Spec.RoleRef.Name = "cluster-admin"
+				ar.Spec.Approval = &nsv1alpha1.AccessRequestApproval{Approved: false, Approver: "jane"}
+			}),
+			ctx:     approvalContext("jane", "approvers"),
+			wantErr: nil,
+		},
+		{
+			name:    "approval changed",
+			old:     accessRequest(approve("jane")),
+			obj:     accessRequest(approve("bob")),
+			ctx:     approvalContext("bob", "approvers"),
+			wantErr: ErrImmutableRequest,
+		},
+		{
+			name:    "spec changed",
+			old:     accessRequest(nil),
+			obj:     accessRequest(func(ar *nsv1alpha1.AccessRequest) { ar.Spec.RoleRef.Name = "admin" }),
+			ctx:     approvalContext("john"),
+			wantErr: ErrImmutableRequest,
+		},
+		{
+			name:    "unrelated update",
+			old:     accessRequest(approve("jane")),
+			obj:     accessRequest(approve("jane")),
+			ctx:     approvalContext("john"),
+			wantErr: nil,
+		},
+	}
+	clusterAdmin := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster-admin"},
+		Rules:      []rbacv1.PolicyRule{{Verbs: []string{"*"}, APIGroups: []string{"*"}, Resources: []string{"*"}}},
+	}
+	// approvers may bind edit, but hold none of the permissions of cluster-admin
+	reviewer := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(clusterAdmin).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				sar := obj.(*authorizationv1.SubjectAccessReview)
+				attrs := sar.Spec.ResourceAttributes
+				sar.Status.Allowed = attrs.Verb == "bind" && attrs.Name == "edit"
+				return nil
+			},
+		}).Build()
+	v := &AccessRequestValidator{ApproverGroups: []string{"approvers"}, Client: reviewer, APIReader: reviewer}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateUpdate(tt.ctx, tt.old, tt.obj)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("AccessRequestValidator.ValidateUpdate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+)
+
+var (
+	_ admission.CustomValidator = &BreakGlassValidator{}
+)
+
+var (
+	ErrInvalidBreakGlass = errors.New("invalid break glass")
+)
+
+// BreakGlassValidator rejects BreakGlasses whose duration is not positive or exceeds the maximum,
+// instead of the controller silently capping it. The spec is immutable, so only creations are validated.
+// It is only registered by config/components/break-glass, as the handler is only served with break-glass access enabled.
+type BreakGlassValidator struct {
+	// MaxDuration caps the duration of every BreakGlass. Zero means no limit.
+	MaxDuration time.Duration
+}
+
+// SetupWebhookWithManager registers the webhook for BreakGlasses in the manager
+func (v *BreakGlassValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&nsv1alpha1.BreakGlass{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *BreakGlassValidator) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	bg, ok := obj.(*nsv1alpha1.BreakGlass)
+	if !ok {
+		return nil, fmt.Errorf("expected a BreakGlass but got a %T", obj)
+	}
+	if bg.Spec.Duration.Duration <= 0 {
+		return nil, fmt.Errorf("%w: duration has to be positive", ErrInvalidBreakGlass)
+	}
+	if v.MaxDuration > 0 && bg.Spec.Duration.Duration > v.MaxDuration {
+		return nil, fmt.Errorf("%w: duration exceeds the maximum of %s", ErrInvalidBreakGlass, v.MaxDuration)
+	}
+	return nil, nil
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *BreakGlassValidator) ValidateUpdate(_ context.Context, _, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *BreakGlassValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	nsv1alpha1 "github.com/tagesspiegel/kubernetes-namespace-permission-manager/api/v1alpha1"
+)
+
+func TestBreakGlassValidator_ValidateCreate(t *testing.T) {
+	breakGlass := func(duration time.Duration) *nsv1alpha1.BreakGlass {
+		return &nsv1alpha1.BreakGlass{
+			ObjectMeta: metav1.ObjectMeta{Name: "incident"},
+			Spec: nsv1alpha1.BreakGlassSpec{
+				Namespace: "team",
+				Subject:   rbacv1.Subject{Kind: rbacv1.UserKind, Name: "oncall", APIGroup: rbacv1.GroupName},
+				Duration:  metav1.Duration{Duration: duration},
+				Reason:    "database outage",
+			},
+		}
+	}
+	tests := []struct {
+		name        string
+		obj         *nsv1alpha1.BreakGlass
+		maxDuration time.Duration
+		wantErr     error
+	}{
+		{
+			name:        "valid duration",
+			obj:         breakGlass(30 * time.Minute),
+			maxDuration: time.Hour,
+			wantErr:     nil,
+		},
+		{
+			name:        "maximum duration",
+			obj:         breakGlass(time.Hour),
+			maxDuration: time.Hour,
+			wantErr:     nil,
+		},
+		{
+			name:        "duration exceeds maximum",
+			obj:         breakGlass(2 * time.Hour),
+			maxDuration: time.Hour,
+			wantErr:     ErrInvalidBreakGlass,
+		},
+		{
+			name:    "no maximum",
+			obj:     breakGlass(24 * time.Hour),
+			wantErr: nil,
+		},
+		{
+			name:        "zero duration",
+			obj:         breakGlass(0),
+			maxDuration: time.Hour,
+			wantErr:     ErrInvalidBreakGlass,
+		},
+		{
+			name:        "negative duration",
+			obj:         breakGlass(-time.Minute),
+			maxDuration: time.Hour,
+			wantErr:     ErrInvalidBreakGlass,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &BreakGlassValidator{MaxDuration: tt.maxDuration}
+			if _, err := v.ValidateCreate(context.Background(), tt.obj); !errors.Is(err, tt.wantErr) {
+				t.Errorf("BreakGlassValidator.ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/policy"
+)
+
+var namespacelog = logf.Log.WithName("namespace-webhook")
+
+var (
+	_ admission.CustomValidator = &NamespaceValidator{}
+)
+
+//+kubebuilder:webhook:path=/validate--v1-namespace,mutating=false,failurePolicy=fail,sideEffects=None,groups="",resources=namespaces,verbs=create;update,versions=v1,name=vnamespace.tagesspiegel.de,admissionReviewVersions=v1
+
+// NamespaceValidator validates the permission annotations of namespaces before they are persisted.
+// Besides the static checks also done by the controller, it makes sure the requesting user holds
+// every permission they try to grant through the annotations.
+type NamespaceValidator struct {
+	Client client.Client
+	// APIReader is used to look up referenced roles without caching all roles of the cluster
+	APIReader client.Reader
+	// Guard protects namespaces and subjects from ever being managed
+	Guard *controller.Guard
+	// Policy is evaluated against the parsed permissions of every namespace
+	Policy *policy.Evaluator
+}
+
+// SetupWebhookWithManager registers the webhook for namespaces in the manager
+func (v *NamespaceValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&corev1.Namespace{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *NamespaceValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	ns, ok := obj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got a %T", obj)
+	}
+	return nil, v.validate(ctx, ns)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *NamespaceValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldNs, ok := oldObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got a %T", oldObj)
+	}
+	ns, ok := newObj.(*corev1.Namespace)
+	if !ok {
+		return nil, fmt.Errorf("expected a Namespace but got a %T", newObj)
+	}
+	// unrelated changes must not be blocked by permissions granted earlier
+	if !permissionsChanged(oldNs, ns) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, ns)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *NamespaceValidator) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+func (v *NamespaceValidator) validate(ctx context.Context, ns *corev1.Namespace) error {
+	if _, ok := ns.Labels[controller.LabelNamespacePermissionControl]; !ok {
+		// the controller ignores the namespace
+		return nil
+	}
+	if v.Guard.IsProtectedNamespace(ns.Name) {
+		return fmt.Errorf("%w: %q", controller.ErrProtectedNamespace, ns.Name)
+	}
+
+	// the same computation as in the controller, so expired subjects are neither checked nor granted
+	desired, err := controller.Desired(ns, time.Now())
+	if err != nil {
+		return err
+	}
+	var subjects []rbacv1.Subject
+	if desired.RoleBinding != nil {
+		subjects = desired.RoleBinding.Subjects
+	}
+	if err := v.Guard.CheckSubjects(subjects); err != nil {
+		return err
+	}
+	if err := v.Policy.Evaluate(policy.Input{Namespace: ns, Subjects: subjects, RoleRef: desired.RoleRef, Rules: desired.Rules}); err != nil {
+		return err
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	reviewer := &accessReviewer{client: v.Client, reader: v.APIReader}
+	if desired.Role != nil {
+		err = reviewer.checkCustomRules(ctx, req.UserInfo, ns.Name, desired.Rules)
+	} else if desired.RoleRef.Name != "" {
+		err = reviewer.checkRoleRef(ctx, req.UserInfo, ns.Name, desired.RoleRef)
+	}
+	if err != nil {
+		namespacelog.Info("rejecting permission annotations", "namespace", ns.Name, "user", req.UserInfo.Username, "reason", err.Error())
+		return err
+	}
+	return nil
+}
+
+// permissionsChanged reports whether the label or any of the permission annotations differ
+func permissionsChanged(oldNs, ns *corev1.Namespace) bool {
+	oldLabel, oldOk := oldNs.Labels[controller.LabelNamespacePermissionControl]
+	label, ok := ns.Labels[controller.LabelNamespacePermissionControl]
+	if oldOk != ok || oldLabel != label {
+		return true
+	}
+	for _, annotation := range controller.PermissionAnnotations {
+		oldValue, oldOk := oldNs.Annotations[annotation]
+		value, ok := ns.Annotations[annotation]
+		if oldOk != ok || oldValue != value {
+			return true
+		}
+	}
+	return false
+}
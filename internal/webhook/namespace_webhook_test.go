@@ -0,0 +1,282 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+// fakeAuthorizer answers subject access reviews with the given allowed verb/resource combinations
+func fakeAuthorizer(allowed map[string][]string, objs ...client.Object) client.Client {
+	return fake.NewClientBuilder().
+		WithScheme(scheme.Scheme).
+		WithObjects(objs...).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+				sar, ok := obj.(*authorizationv1.SubjectAccessReview)
+				if !ok {
+					return c.Create(ctx, obj, opts...)
+				}
+				attrs := sar.Spec.ResourceAttributes
+				sar.Status.Allowed = slices.Contains(allowed[attrs.Verb], attrs.Resource)
+				return nil
+			},
+		}).
+		Build()
+}
+
+func admissionContext(user string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			UserInfo: authenticationv1.UserInfo{Username: user},
+		},
+	})
+}
+
+func TestNamespaceValidator_ValidateCreate(t *testing.T) {
+	clusterRole := &rbacv1.ClusterRole{
+		ObjectMeta: metav1.ObjectMeta{Name: "edit"},
+		Rules: []rbacv1.PolicyRule{
+			{Verbs: []string{"get", "update"}, APIGroups: []string{"apps"}, Resources: []string{"deployments"}},
+		},
+	}
+	tests := []struct {
+		name    string
+		allowed map[string][]string
+		ns      *corev1.Namespace
+		wantErr error
+	}{
+		{
+			name: "namespace without label",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Annotations: map[string]string{controller.AnnotationNamespaceCustomRoleRules: "verbs=*;apiGroups=*;resources=*"},
+			}},
+			wantErr: nil,
+		},
+		{
+			name: "protected namespace",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "kube-system",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+			}},
+			wantErr: controller.ErrProtectedNamespace,
+		},
+		{
+			name: "denied subject",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Labels:      map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=system:masters"},
+			}},
+			wantErr: controller.ErrDeniedSubject,
+		},
+		{
+			name: "expired denied subject",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Labels:      map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=system:masters;expires=2000-01-01T00:00:00Z"},
+			}},
+		},
+		{
+			name: "invalid annotation",
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:        "test",
+				Labels:      map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{controller.AnnotationNamespaceCustomRoleRules: "verbs=get;foo=bar"},
+			}},
+			wantErr: controller.ErrInvalidKeyInCustomRole,
+		},
+		{
+			name:    "custom rules held by the requester",
+			allowed: map[string][]string{"get": {"pods"}, "list": {"pods"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceCustomRoleRules:     "verbs=get,list;apiGroups=;resources=pods",
+					controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-foo",
+				},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "custom rules not held by the requester",
+			allowed: map[string][]string{"get": {"pods"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceCustomRoleRules: "verbs=get,delete;apiGroups=;resources=pods",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+		{
+			name:    "custom rules with only escalate",
+			allowed: map[string][]string{"escalate": {"roles"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceCustomRoleRules:     "verbs=*;apiGroups=*;resources=*",
+					controller.AnnotationNamespaceRoleBindingSubjects: "kind=Group;name=team-foo",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+		{
+			name:    "custom rules with bind on the managed role",
+			allowed: map[string][]string{"bind": {"roles"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceCustomRoleRules: "verbs=*;apiGroups=*;resources=*",
+				},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "cluster role with only escalate",
+			allowed: map[string][]string{"escalate": {"roles"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+		{
+			name:    "cluster role with bind",
+			allowed: map[string][]string{"bind": {"clusterroles"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "cluster role with held permissions",
+			allowed: map[string][]string{"get": {"deployments"}, "update": {"deployments"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "cluster role without held permissions",
+			allowed: map[string][]string{"get": {"deployments"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=edit",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+		{
+			name:    "missing cluster role with bind",
+			allowed: map[string][]string{"bind": {"clusterroles"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=missing",
+				},
+			}},
+			wantErr: nil,
+		},
+		{
+			name:    "missing cluster role without bind",
+			allowed: map[string][]string{"get": {"deployments"}, "update": {"deployments"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=ClusterRole;apiGroup=rbac.authorization.k8s.io;name=missing",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+		{
+			name:    "missing role without bind",
+			allowed: map[string][]string{"get": {"deployments"}},
+			ns: &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:   "test",
+				Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+				Annotations: map[string]string{
+					controller.AnnotationNamespaceRoleBindingRoleRef: "kind=Role;apiGroup=rbac.authorization.k8s.io;name=missing",
+				},
+			}},
+			wantErr: ErrEscalation,
+		},
+	}
+	guard, err := controller.NewGuard([]string{"kube-*"}, "kind=Group;name=system:masters")
+	if err != nil {
+		t.Fatalf("NewGuard() error = %v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fakeAuthorizer(tt.allowed, clusterRole)
+			v := &NamespaceValidator{
+				Client:    c,
+				APIReader: c,
+				Guard:     guard,
+			}
+			_, err := v.ValidateCreate(admissionContext("jane"), tt.ns)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("NamespaceValidator.ValidateCreate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNamespaceValidator_ValidateUpdate(t *testing.T) {
+	old := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+		Name:   "test",
+		Labels: map[string]string{controller.LabelNamespacePermissionControl: "true"},
+		Annotations: map[string]string{
+			controller.AnnotationNamespaceCustomRoleRules: "verbs=*;apiGroups=*;resources=*",
+		},
+	}}
+	unrelated := old.DeepCopy()
+	unrelated.Labels["foo"] = "bar"
+	changed := old.DeepCopy()
+	changed.Annotations[controller.AnnotationNamespaceCustomRoleRules] = "verbs=delete;apiGroups=*;resources=*"
+
+	c := fakeAuthorizer(nil)
+	v := &NamespaceValidator{Client: c, APIReader: c}
+	if _, err := v.ValidateUpdate(admissionContext("jane"), old, unrelated); err != nil {
+		t.Errorf("NamespaceValidator.ValidateUpdate() with unrelated change error = %v, want nil", err)
+	}
+	if _, err := v.ValidateUpdate(admissionContext("jane"), old, changed); !errors.Is(err, ErrEscalation) {
+		t.Errorf("NamespaceValidator.ValidateUpdate() with changed rules error = %v, want %v", err, ErrEscalation)
+	}
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+var rbaclog = logf.Log.WithName("rbac-webhook")
+
+var (
+	_ admission.CustomValidator = &ManagedRBACValidator{}
+)
+
+var (
+	ErrManagedObject = errors.New("object is managed by the namespace permission controller")
+)
+
+// garbageCollectorUsername is the user deleting objects whose owner is gone
+const garbageCollectorUsername = "system:serviceaccount:kube-system:generic-garbage-collector"
+
+//+kubebuilder:webhook:path=/validate-rbac-authorization-k8s-io-v1-role,mutating=false,failurePolicy=fail,sideEffects=None,groups=rbac.authorization.k8s.io,resources=roles,verbs=update;delete,versions=v1,name=vrole.tagesspiegel.de,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-rbac-authorization-k8s-io-v1-rolebinding,mutating=false,failurePolicy=fail,sideEffects=None,groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=update;delete,versions=v1,name=vrolebinding.tagesspiegel.de,admissionReviewVersions=v1
+
+// ManagedRBACValidator rejects manual updates and deletions of Roles and RoleBindings managed by the controller.
+// Only the controller itself and members of the break-glass groups may change them.
+type ManagedRBACValidator struct {
+	// APIReader is used to look up the namespace of deleted objects
+	APIReader client.Reader
+	// Enabled turns the protection on. If false, all requests are allowed.
+	Enabled bool
+	// ControllerUsername is the username of the controller's ServiceAccount,
+	// e.g. system:serviceaccount:<namespace>:<name>
+	ControllerUsername string
+	// BreakGlassGroups are groups whose members may always change managed objects
+	BreakGlassGroups []string
+}
+
+// SetupWebhookWithManager registers the webhooks for Roles and RoleBindings in the manager
+func (v *ManagedRBACValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	err := ctrl.NewWebhookManagedBy(mgr).
+		For(&rbacv1.Role{}).
+		WithValidator(v).
+		Complete()
+	if err != nil {
+		return err
+	}
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&rbacv1.RoleBinding{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *ManagedRBACValidator) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *ManagedRBACValidator) ValidateUpdate(ctx context.Context, oldObj, _ runtime.Object) (admission.Warnings, error) {
+	// the old object decides whether the object is managed, so the label can't be removed to escape the protection
+	return v.validate(ctx, oldObj)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *ManagedRBACValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return v.validate(ctx, obj)
+}
+
+func (v *ManagedRBACValidator) validate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	if !v.Enabled {
+		return nil, nil
+	}
+	o, ok := obj.(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("expected a Role or RoleBinding but got a %T", obj)
+	}
+	if o.GetLabels()[controller.LabelManagedBy] != controller.ManagedByValue {
+		return nil, nil
+	}
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if v.ControllerUsername != "" && req.UserInfo.Username == v.ControllerUsername {
+		return nil, nil
+	}
+	for _, group := range req.UserInfo.Groups {
+		if slices.Contains(v.BreakGlassGroups, group) {
+			rbaclog.Info("break-glass change of managed object", "user", req.UserInfo.Username, "group", group,
+				"operation", req.Operation, "kind", req.Kind.Kind, "namespace", o.GetNamespace(), "name", o.GetName())
+			return admission.Warnings{
+				fmt.Sprintf("%s %s/%s is managed by %s, the change will be reverted once the namespace is reconciled again",
+					req.Kind.Kind, o.GetNamespace(), o.GetName(), controller.ManagedByValue),
+			}, nil
+		}
+	}
+	if req.Operation == admissionv1.Delete {
+		// objects owned by e.g. an AccessRequest are removed together with their owner
+		if req.UserInfo.Username == garbageCollectorUsername && len(o.GetOwnerReferences()) > 0 {
+			return nil, nil
+		}
+		// namespace deletion has to clean up all objects in the namespace
+		terminating, err := v.namespaceTerminating(ctx, o.GetNamespace())
+		if err != nil || terminating {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w: %s %s/%s can only be changed through the annotations of namespace %q",
+		ErrManagedObject, req.Kind.Kind, o.GetNamespace(), o.GetName(), o.GetLabels()[controller.LabelNamespaceName])
+}
+
+func (v *ManagedRBACValidator) namespaceTerminating(ctx context.Context, name string) (bool, error) {
+	ns := &corev1.Namespace{}
+	if err := v.APIReader.Get(ctx, client.ObjectKey{Name: name}, ns); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return ns.DeletionTimestamp != nil || ns.Status.Phase == corev1.NamespaceTerminating, nil
+}
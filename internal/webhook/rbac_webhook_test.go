@@ -0,0 +1,153 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/tagesspiegel/kubernetes-namespace-permission-manager/internal/controller"
+)
+
+func rbacAdmissionContext(op admissionv1.Operation, user string, groups ...string) context.Context {
+	return admission.NewContextWithRequest(context.Background(), admission.Request{
+		AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: op,
+			Kind:      metav1.GroupVersionKind{Group: rbacv1.GroupName, Version: "v1", Kind: "RoleBinding"},
+			UserInfo:  authenticationv1.UserInfo{Username: user, Groups: groups},
+		},
+	})
+}
+
+func TestManagedRBACValidator_ValidateDelete(t *testing.T) {
+	managed := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{
+		Name:      "test",
+		Namespace: "test",
+		Labels: map[string]string{
+			controller.LabelManagedBy:     controller.ManagedByValue,
+			controller.LabelNamespaceName: "test",
+		},
+	}}
+	owned := managed.DeepCopy()
+	owned.OwnerReferences = []metav1.OwnerReference{{APIVersion: "ns.tagesspiegel.de/v1alpha1", Kind: "AccessRequest", Name: "test"}}
+	unmanaged := &rbacv1.RoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "foo", Namespace: "test"}}
+	active := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+	}
+	terminating := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+	}
+	tests := []struct {
+		name     string
+		disabled bool
+		ns       *corev1.Namespace
+		obj      *rbacv1.RoleBinding
+		ctx      context.Context
+		wantErr  error
+	}{
+		{
+			name:    "user deletes managed object",
+			ns:      active,
+			obj:     managed,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, "jane", "developers"),
+			wantErr: ErrManagedObject,
+		},
+		{
+			name:     "protection disabled",
+			disabled: true,
+			ns:       active,
+			obj:      managed,
+			ctx:      rbacAdmissionContext(admissionv1.Delete, "jane", "developers"),
+			wantErr:  nil,
+		},
+		{
+			name:    "user deletes unmanaged object",
+			ns:      active,
+			obj:     unmanaged,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, "jane", "developers"),
+			wantErr: nil,
+		},
+		{
+			name:    "controller deletes managed object",
+			ns:      active,
+			obj:     managed,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, "system:serviceaccount:system:controller-manager"),
+			wantErr: nil,
+		},
+		{
+			name:    "break-glass group deletes managed object",
+			ns:      active,
+			obj:     managed,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, "jane", "developers", "oncall"),
+			wantErr: nil,
+		},
+		{
+			name:    "garbage collector deletes owned object",
+			ns:      active,
+			obj:     owned,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, garbageCollectorUsername),
+			wantErr: nil,
+		},
+		{
+			name:    "garbage collector deletes object without owner",
+			ns:      active,
+			obj:     managed,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, garbageCollectorUsername),
+			wantErr: ErrManagedObject,
+		},
+		{
+			name:    "namespace is terminating",
+			ns:      terminating,
+			obj:     managed,
+			ctx:     rbacAdmissionContext(admissionv1.Delete, "system:serviceaccount:kube-system:namespace-controller"),
+			wantErr: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &ManagedRBACValidator{
+				APIReader:          fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(tt.ns).Build(),
+				Enabled:            !tt.disabled,
+				ControllerUsername: "system:serviceaccount:system:controller-manager",
+				BreakGlassGroups:   []string{"oncall"},
+			}
+			_, err := v.ValidateDelete(tt.ctx, tt.obj)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("ManagedRBACValidator.ValidateDelete() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestManagedRBACValidator_ValidateUpdate(t *testing.T) {
+	old := &rbacv1.Role{ObjectMeta: metav1.ObjectMeta{
+		Name:      "test",
+		Namespace: "test",
+		Labels:    map[string]string{controller.LabelManagedBy: controller.ManagedByValue},
+	}}
+	// removing the label must not circumvent the protection
+	updated := old.DeepCopy()
+	updated.Labels = nil
+
+	v := &ManagedRBACValidator{
+		APIReader:          fake.NewClientBuilder().WithScheme(scheme.Scheme).Build(),
+		Enabled:            true,
+		ControllerUsername: "system:serviceaccount:system:controller-manager",
+	}
+	if _, err := v.ValidateUpdate(rbacAdmissionContext(admissionv1.Update, "jane"), old, updated); !errors.Is(err, ErrManagedObject) {
+		t.Errorf("ManagedRBACValidator.ValidateUpdate() error = %v, want %v", err, ErrManagedObject)
+	}
+	if _, err := v.ValidateUpdate(rbacAdmissionContext(admissionv1.Update, v.ControllerUsername), old, updated); err != nil {
+		t.Errorf("ManagedRBACValidator.ValidateUpdate() by controller error = %v, want nil", err)
+	}
+}